@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type accountKey string
+
+const accountCtx accountKey = "account"
+
+type UpdateUserRolePayload struct {
+	Role string `json:"role" validate:"required,oneof=user support admin"`
+}
+
+// GetAdminUsers godoc
+//
+//	@Summary		Lists users
+//	@Description	Lists every account, including pending and deactivated ones. Support and admin only.
+//	@Tags			admin
+//	@Produce		json
+//	@Param			search	query		string	false	"Matches part of the username or email"
+//	@Param			role	query		string	false	"Role"		Enums(user, support, admin)
+//	@Param			status	query		string	false	"Status"	Enums(active, pending, deactivated)
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{array}		store.User
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users [get]
+func (app *application) getAdminUsersHandler(w http.ResponseWriter, r *http.Request) {
+	fq := &store.UserFilterQuery{
+		Limit:  20,
+		Offset: 0,
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(fq)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	users, err := app.store.Users.List(ctx, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, users)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetAdminUser godoc
+//
+//	@Summary		Fetches a user account
+//	@Description	Fetches an account whatever its state. Support and admin only.
+//	@Tags			admin
+//	@Produce		json
+//	@Param			userID	path		int	true	"User ID"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID} [get]
+func (app *application) getAdminUserHandler(w http.ResponseWriter, r *http.Request) {
+	account := getAccountFromCtx(r)
+
+	err := app.writeJsonResponse(w, http.StatusOK, account)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// DeactivateUser godoc
+//
+//	@Summary		Deactivates a user account
+//	@Description	Locks an active account out and ends all of its sessions. Support may only act on users, admins on users and support staff.
+//	@Tags			admin
+//	@Param			userID	path	int	true	"User ID"
+//	@Success		204		"Account deactivated"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/deactivate [post]
+func (app *application) deactivateUserHandler(w http.ResponseWriter, r *http.Request) {
+	account := getAccountFromCtx(r)
+
+	ctx := r.Context()
+
+	sessionIDs, err := app.store.Users.Deactivate(ctx, account.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	for _, sessionID := range sessionIDs {
+		err = app.revokeSession(ctx, sessionID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	err = app.forgetUser(ctx, account.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReactivateUser godoc
+//
+//	@Summary		Reactivates a user account
+//	@Description	Restores a deactivated account. Support may only act on users, admins on users and support staff.
+//	@Tags			admin
+//	@Param			userID	path	int	true	"User ID"
+//	@Success		204		"Account reactivated"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/reactivate [post]
+func (app *application) reactivateUserHandler(w http.ResponseWriter, r *http.Request) {
+	account := getAccountFromCtx(r)
+
+	ctx := r.Context()
+
+	err := app.store.Users.Reactivate(ctx, account.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.forgetUser(ctx, account.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendInvitation godoc
+//
+//	@Summary		Resends the activation email
+//	@Description	Replaces the invitation of an account that was never activated and emails a new activation link. Support may only act on users, admins on users and support staff.
+//	@Tags			admin
+//	@Param			userID	path	int	true	"User ID"
+//	@Success		204		"Invitation sent"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/invitation [post]
+func (app *application) resendInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	account := getAccountFromCtx(r)
+
+	ctx := r.Context()
+
+	plainToken := uuid.New().String()
+	hash := sha256.Sum256([]byte(plainToken))
+	hashCode := hex.EncodeToString(hash[:])
+
+	err := app.store.Users.Reinvite(ctx, account.ID, hashCode, app.config.mail.expiry)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		case errors.Is(err, store.ErrNotPending):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	status, err := app.sendActivationEmail(account, plainToken)
+	if err != nil {
+		app.logger.Errorw("error resending welcome email", "error", err)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("Email sent", "status code", status)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateUserRole godoc
+//
+//	@Summary		Changes the role of a user
+//	@Description	Sets the role of an account. Admins only, and never on themselves or other admins.
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int						true	"User ID"
+//	@Param			payload	body		UpdateUserRolePayload	true	"Role payload"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/role [put]
+func (app *application) updateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	account := getAccountFromCtx(r)
+
+	var payload UpdateUserRolePayload
+
+	err := readJson(w, r, &payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	err = app.store.Users.SetRole(ctx, account.ID, payload.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.forgetUser(ctx, account.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	account.Role = payload.Role
+
+	err = app.writeJsonResponse(w, http.StatusOK, account)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) accountsContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+
+		account, err := app.store.Users.GetAccount(ctx, userID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, accountCtx, account)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requireOutranks lets staff manage only accounts below their own role, and
+// never their own.
+func (app *application) requireOutranks(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := getUserFromCtx(r)
+		account := getAccountFromCtx(r)
+
+		if user == nil || account == nil || user.ID == account.ID || !user.Outranks(account) {
+			app.forbiddenError(w, r, fmt.Errorf("cannot manage this account"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func getAccountFromCtx(r *http.Request) *store.User {
+	account, _ := r.Context().Value(accountCtx).(*store.User)
+
+	return account
+}
+
+// Drop the cached user so account changes apply on the next request
+func (app *application) forgetUser(ctx context.Context, userID int64) error {
+	if !app.config.redisCfg.enabled {
+		return nil
+	}
+
+	return app.cacheStorage.Users.Delete(ctx, userID)
+}
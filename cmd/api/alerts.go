@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ecetinerdem/forseerv2/internal/marketdata"
+	"github.com/ecetinerdem/forseerv2/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type alertKey string
+
+const alertCtx alertKey = "alert"
+
+type CreateAlertPayload struct {
+	Kind        string  `json:"kind" validate:"required,oneof=price portfolio_change"`
+	Symbol      string  `json:"symbol" validate:"required_if=Kind price,omitempty,symbol"`
+	PortfolioID int64   `json:"portfolio_id" validate:"required_if=Kind portfolio_change"`
+	Condition   string  `json:"condition" validate:"required,oneof=above below"`
+	Threshold   float64 `json:"threshold"`
+}
+
+type UpdateAlertPayload struct {
+	Condition *string  `json:"condition" validate:"omitempty,oneof=above below"`
+	Threshold *float64 `json:"threshold"`
+	Active    *bool    `json:"active"`
+}
+
+// CreateAlert godoc
+//
+//	@Summary		Creates an alert
+//	@Description	Creates a price alert on a symbol (e.g. AAPL below 150) or a portfolio alert on its daily change in percent (e.g. below -5). Alerts are emailed once per crossing and re-arm when the condition clears.
+//	@Tags			alerts
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateAlertPayload	true	"Alert payload"
+//	@Success		201		{object}	store.Alert
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/alerts [post]
+func (app *application) createAlertHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	var payload CreateAlertPayload
+	err := readJson(w, r, &payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	alert := &store.Alert{
+		UserID:    user.ID,
+		Kind:      payload.Kind,
+		Condition: payload.Condition,
+		Threshold: payload.Threshold,
+	}
+
+	ctx := r.Context()
+
+	switch payload.Kind {
+	case store.AlertPrice:
+		alert.Symbol, err = marketdata.NormalizeSymbol(payload.Symbol)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+		if payload.Threshold <= 0 {
+			app.badRequestError(w, r, errors.New("price threshold must be greater than 0"))
+			return
+		}
+	case store.AlertPortfolioChange:
+		// Only the owner's portfolios can be watched
+		_, err = app.getPortfolio(ctx, payload.PortfolioID, user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+		alert.PortfolioID = &payload.PortfolioID
+	}
+
+	err = app.store.Alerts.Create(ctx, alert)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusCreated, alert)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetAlerts godoc
+//
+//	@Summary		Lists alerts
+//	@Description	Lists the authenticated user's alerts, newest first
+//	@Tags			alerts
+//	@Produce		json
+//	@Success		200	{array}		store.Alert
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/alerts [get]
+func (app *application) getAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	ctx := r.Context()
+
+	alerts, err := app.store.Alerts.GetByUser(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, alerts)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetAlert godoc
+//
+//	@Summary		Fetches an alert
+//	@Description	Fetches an alert by its ID
+//	@Tags			alerts
+//	@Produce		json
+//	@Param			alertID	path		int	true	"Alert ID"
+//	@Success		200		{object}	store.Alert
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/alerts/{alertID} [get]
+func (app *application) getAlertHandler(w http.ResponseWriter, r *http.Request) {
+	alert := getAlertFromCtx(r)
+
+	err := app.writeJsonResponse(w, http.StatusOK, alert)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// UpdateAlert godoc
+//
+//	@Summary		Updates an alert
+//	@Description	Changes an alert's condition, threshold or active flag. Updating re-arms the alert.
+//	@Tags			alerts
+//	@Accept			json
+//	@Produce		json
+//	@Param			alertID	path		int					true	"Alert ID"
+//	@Param			payload	body		UpdateAlertPayload	true	"Alert payload"
+//	@Success		200		{object}	store.Alert
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/alerts/{alertID} [patch]
+func (app *application) updateAlertHandler(w http.ResponseWriter, r *http.Request) {
+	alert := getAlertFromCtx(r)
+
+	var payload UpdateAlertPayload
+	err := readJson(w, r, &payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if payload.Condition != nil {
+		alert.Condition = *payload.Condition
+	}
+	if payload.Threshold != nil {
+		if alert.Kind == store.AlertPrice && *payload.Threshold <= 0 {
+			app.badRequestError(w, r, errors.New("price threshold must be greater than 0"))
+			return
+		}
+		alert.Threshold = *payload.Threshold
+	}
+	if payload.Active != nil {
+		alert.Active = *payload.Active
+	}
+
+	ctx := r.Context()
+
+	err = app.store.Alerts.Update(ctx, alert)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, alert)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// DeleteAlert godoc
+//
+//	@Summary		Deletes an alert
+//	@Description	Removes an alert permanently by its ID
+//	@Tags			alerts
+//	@Param			alertID	path	int	true	"Alert ID"
+//	@Success		204		"Alert deleted successfully"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/alerts/{alertID} [delete]
+func (app *application) deleteAlertHandler(w http.ResponseWriter, r *http.Request) {
+	alert := getAlertFromCtx(r)
+
+	ctx := r.Context()
+
+	err := app.store.Alerts.Delete(ctx, alert.ID, alert.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) alertsContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := getUserFromCtx(r)
+		if user == nil {
+			app.unAuthorizedError(w, r, errors.New("unauthorized"))
+			return
+		}
+
+		alertID, err := strconv.ParseInt(chi.URLParam(r, "alertID"), 10, 64)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+
+		alert, err := app.store.Alerts.GetByID(ctx, alertID, user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, alertCtx, alert)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getAlertFromCtx(r *http.Request) *store.Alert {
+	alert, _ := r.Context().Value(alertCtx).(*store.Alert)
+
+	return alert
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/analysis"
+	"github.com/ecetinerdem/forseerv2/internal/marketdata"
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+// GetPortfolioAnalysis godoc
+//
+//	@Summary		Analyzes a portfolio
+//	@Description	Computes time-weighted return, annualized volatility, max drawdown, Sharpe/Sortino ratios and per-holding contribution. The ledger is replayed day by day and every holding is valued in the base currency at that day's rate; holdings without prices or rates are listed as unpriced or unconverted. With a benchmark (the portfolio's own or the benchmark query) it adds cumulative return series for both, alpha, beta and tracking error.
+//	@Tags			portfolios
+//	@Produce		json
+//	@Param			portfolioID	path		int		true	"Portfolio ID"
+//	@Param			period		query		string	false	"Period (1m, 3m, 6m, ytd, 1y, 3y, 5y)"	default(1y)
+//	@Param			benchmark	query		string	false	"Benchmark symbol, overrides the portfolio's benchmark"
+//	@Success		200			{object}	store.PortfolioAnalysis
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error	"Ledger sells more shares than it holds"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/{portfolioID}/analysis [get]
+func (app *application) getPortfolioAnalysisHandler(w http.ResponseWriter, r *http.Request) {
+	portfolio := getPortfolioFromCtx(r)
+
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "1y"
+	}
+
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	from, err := analysis.PeriodStart(period, to)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	benchmarkSymbol := portfolio.BenchmarkSymbol
+	if b := r.URL.Query().Get("benchmark"); b != "" {
+		benchmarkSymbol, err = marketdata.NormalizeSymbol(b)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	transactions, err := app.store.Transactions.GetByPortfolio(ctx, portfolio.ID, "")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	symbols, err := analysis.HeldSymbols(transactions, portfolio.CostBasisMethod, from, to)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInsufficientShares):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// Holdings without history are reported as unpriced by the analysis
+	histories := make([]store.StockHistory, 0, len(symbols))
+	for _, symbol := range symbols {
+		history, err := app.getStockHistory(ctx, symbol, from, to)
+		if err != nil {
+			if errors.Is(err, marketdata.ErrSymbolNotFound) || errors.Is(err, marketdata.ErrNoData) || errors.Is(err, marketdata.ErrInvalidSymbol) {
+				app.logger.Warnw("no price history for holding", "symbol", symbol, "error", err)
+				continue
+			}
+			app.internalServerError(w, r, err)
+			return
+		}
+		histories = append(histories, *history)
+	}
+
+	var benchmark *store.StockHistory
+	if benchmarkSymbol != "" {
+		benchmark, err = app.getStockHistory(ctx, benchmarkSymbol, from, to)
+		if err != nil {
+			switch {
+			case errors.Is(err, marketdata.ErrSymbolNotFound), errors.Is(err, marketdata.ErrNoData):
+				app.notFoundError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+	}
+
+	rates := func(currencies []string, date time.Time) (map[string]float64, error) {
+		return app.getHistoricalFXRates(ctx, currencies, portfolio.BaseCurrency, date)
+	}
+
+	result, err := analysis.Analyze(portfolio, transactions, histories, benchmark, rates, app.config.analysis.riskFreeRate)
+	if err != nil {
+		switch {
+		case errors.Is(err, analysis.ErrInsufficientData):
+			app.notFoundError(w, r, err)
+		case errors.Is(err, store.ErrInsufficientShares):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	result.Period = period
+
+	err = app.writeJsonResponse(w, http.StatusOK, result)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
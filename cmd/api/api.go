@@ -8,14 +8,19 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/ecetinerdem/forseerv2/docs" //Required for generating swagger docs
+	"github.com/ecetinerdem/forseerv2/internal/alerts"
 	"github.com/ecetinerdem/forseerv2/internal/auth"
 	"github.com/ecetinerdem/forseerv2/internal/env"
+	"github.com/ecetinerdem/forseerv2/internal/fx"
 	"github.com/ecetinerdem/forseerv2/internal/mailer"
+	"github.com/ecetinerdem/forseerv2/internal/marketdata"
 	"github.com/ecetinerdem/forseerv2/internal/ratelimiter"
+	"github.com/ecetinerdem/forseerv2/internal/snapshots"
 	"github.com/ecetinerdem/forseerv2/internal/store"
 	"github.com/ecetinerdem/forseerv2/internal/store/cache"
 	"github.com/go-chi/chi/v5"
@@ -26,13 +31,18 @@ import (
 )
 
 type application struct {
-	config        config
-	store         *store.Storage
-	cacheStorage  cache.Storage
-	logger        *zap.SugaredLogger
-	mailer        mailer.Client
-	authenticator auth.Authenticator
-	rateLimiter   ratelimiter.Limiter
+	config         config
+	store          *store.Storage
+	cacheStorage   cache.Storage
+	logger         *zap.SugaredLogger
+	mailer         mailer.Client
+	authenticator  auth.Authenticator
+	rateLimiter    ratelimiter.Limiter
+	marketData     marketdata.Provider
+	fx             *fx.Converter
+	alerts         *alerts.Evaluator
+	snapshots      *snapshots.Recorder
+	passwordResets chan string
 }
 
 type config struct {
@@ -46,6 +56,12 @@ type config struct {
 	auth        authConfig
 	redisCfg    redisConfig
 	rateLimiter ratelimiter.Config
+	marketData  marketDataConfig
+	analysis    analysisConfig
+	fx          fxConfig
+	alerts      alertsConfig
+	securities  securitiesConfig
+	snapshots   snapshotsConfig
 }
 
 type dbConfig struct {
@@ -66,8 +82,10 @@ type sendGridConfig struct {
 }
 
 type authConfig struct {
-	basic basicConfig
-	token tokenConfig
+	basic       basicConfig
+	token       tokenConfig
+	mfa         mfaConfig
+	resetExpiry time.Duration
 }
 type basicConfig struct {
 	user string
@@ -75,9 +93,46 @@ type basicConfig struct {
 }
 
 type tokenConfig struct {
-	secret string
-	expiry time.Duration
-	iss    string
+	secret        string
+	expiry        time.Duration
+	refreshExpiry time.Duration
+	iss           string
+}
+
+type mfaConfig struct {
+	issuer          string
+	challengeExpiry time.Duration
+	lockout         time.Duration
+}
+
+type securitiesConfig struct {
+	validate bool
+}
+
+type alertsConfig struct {
+	enabled  bool
+	interval time.Duration
+}
+
+type snapshotsConfig struct {
+	enabled  bool
+	interval time.Duration
+	lookback int
+}
+
+type fxConfig struct {
+	pivot string
+}
+
+type marketDataConfig struct {
+	provider string
+	dataDir  string
+	baseURL  string
+	apiKey   string
+}
+
+type analysisConfig struct {
+	riskFreeRate float64
 }
 
 type redisConfig struct {
@@ -125,12 +180,95 @@ func (app *application) mount() *chi.Mux {
 			r.Route("/{userID}", func(r chi.Router) {
 				r.Use(app.TokenAuthMiddleware)
 				r.Get("/", app.getUserHandler)
+				r.Get("/export", app.exportUserHandler)
 			})
 		})
-		//Only Public Route
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(app.TokenAuthMiddleware)
+			r.Use(app.RequireRole(store.UserRoleSupport))
+			r.Route("/users", func(r chi.Router) {
+				r.Get("/", app.getAdminUsersHandler)
+				r.Route("/{userID}", func(r chi.Router) {
+					r.Use(app.accountsContextMiddleware)
+					r.Get("/", app.getAdminUserHandler)
+					r.With(app.requireOutranks).Post("/deactivate", app.deactivateUserHandler)
+					r.With(app.requireOutranks).Post("/reactivate", app.reactivateUserHandler)
+					r.With(app.requireOutranks).Post("/invitation", app.resendInvitationHandler)
+					r.With(app.RequireRole(store.UserRoleAdmin), app.requireOutranks).Put("/role", app.updateUserRoleHandler)
+				})
+			})
+		})
+
+		//Public except for logout, mfa and sessions
 		r.Route("/authentication", func(r chi.Router) {
 			r.Post("/user", app.registerUserHandler)
 			r.Post("/token", app.createTokenHandler)
+			r.Post("/token/mfa", app.verifyMFAChallengeHandler)
+			r.Post("/refresh", app.refreshTokenHandler)
+			r.Post("/password/forgot", app.forgotPasswordHandler)
+			r.Post("/password/reset", app.resetPasswordHandler)
+			r.With(app.TokenAuthMiddleware).Post("/logout", app.logoutHandler)
+			r.With(app.TokenAuthMiddleware).Post("/logout-all", app.logoutAllHandler)
+			r.Route("/mfa", func(r chi.Router) {
+				r.Use(app.TokenAuthMiddleware)
+				r.Post("/enroll", app.enrollMFAHandler)
+				r.Post("/verify", app.verifyMFAHandler)
+				r.Post("/recovery-codes", app.regenerateRecoveryCodesHandler)
+				r.Post("/disable", app.disableMFAHandler)
+			})
+			r.Route("/sessions", func(r chi.Router) {
+				r.Use(app.TokenAuthMiddleware)
+				r.Get("/", app.getSessionsHandler)
+				r.Delete("/{sessionID}", app.revokeSessionHandler)
+			})
+		})
+
+		r.Route("/stocks", func(r chi.Router) {
+			r.Use(app.TokenAuthMiddleware)
+			r.Get("/{symbol}/quote", app.getStockQuoteHandler)
+			r.Get("/{symbol}/history", app.getStockHistoryHandler)
+		})
+
+		r.Route("/alerts", func(r chi.Router) {
+			r.Use(app.TokenAuthMiddleware)
+			r.Post("/", app.createAlertHandler)
+			r.Get("/", app.getAlertsHandler)
+			r.Route("/{alertID}", func(r chi.Router) {
+				r.Use(app.alertsContextMiddleware)
+				r.Get("/", app.getAlertHandler)
+				r.Patch("/", app.updateAlertHandler)
+				r.Delete("/", app.deleteAlertHandler)
+			})
+		})
+
+		r.Route("/securities", func(r chi.Router) {
+			r.Use(app.TokenAuthMiddleware)
+			r.Get("/search", app.searchSecuritiesHandler)
+		})
+
+		r.Route("/watchlists", func(r chi.Router) {
+			r.Use(app.TokenAuthMiddleware)
+			r.Post("/", app.createWatchlistHandler)
+			r.Get("/", app.getWatchlistsHandler)
+			r.Route("/{watchlistID}", func(r chi.Router) {
+				r.Use(app.watchlistsContextMiddleware)
+				r.Get("/", app.getWatchlistHandler)
+				r.Patch("/", app.updateWatchlistHandler)
+				r.Delete("/", app.deleteWatchlistHandler)
+
+				r.Route("/items", func(r chi.Router) {
+					r.Post("/", app.addWatchlistItemHandler)
+					r.Put("/order", app.reorderWatchlistHandler)
+					r.Patch("/{symbol}", app.updateWatchlistItemHandler)
+					r.Delete("/{symbol}", app.removeWatchlistItemHandler)
+				})
+			})
+		})
+
+		r.Route("/corporate-actions", func(r chi.Router) {
+			r.With(app.BasicAuthMiddleware()).Post("/", app.createCorporateActionHandler)
+			r.With(app.TokenAuthMiddleware).Get("/", app.getCorporateActionsHandler)
 		})
 
 		r.Route("/portfolios", func(r chi.Router) {
@@ -138,18 +276,51 @@ func (app *application) mount() *chi.Mux {
 			r.Post("/", app.createPortfolioHandler)
 			r.Get("/", app.getPortfoliosHandler)
 			r.Get("/search", app.searchPortfoliosHandler)
+			r.Get("/exposure", app.getUserExposureHandler)
+			r.Get("/summary", app.getPortfoliosSummaryHandler)
+			r.Put("/invitations/{token}", app.acceptPortfolioInvitationHandler)
 			r.Route("/{portfolioID}", func(r chi.Router) {
 				r.Use(app.portfoliosContextMiddleware)
 				r.Get("/", app.getPortfolioHandler)
-				r.Patch("/", app.updatePortfolioHandler)
-				r.Delete("/", app.deletePortfolioHandler)
+				r.With(app.requirePortfolioRole(store.RoleEditor)).Patch("/", app.updatePortfolioHandler)
+				r.With(app.requirePortfolioRole(store.RoleOwner)).Delete("/", app.deletePortfolioHandler)
+				r.Get("/valuation", app.getPortfolioValuationHandler)
+				r.Get("/analysis", app.getPortfolioAnalysisHandler)
+				r.Get("/realized", app.getRealizedGainsHandler)
+				r.Get("/dividends", app.getDividendIncomeHandler)
+				r.Get("/exposure", app.getPortfolioExposureHandler)
+				r.Get("/history", app.getPortfolioHistoryHandler)
+				r.Get("/targets", app.getTargetsHandler)
+				r.With(app.requirePortfolioRole(store.RoleEditor)).Put("/targets", app.setTargetsHandler)
+				// Only suggests orders, so viewers may rebalance too
+				r.Post("/rebalance", app.rebalancePortfolioHandler)
+				r.With(app.requirePortfolioRole(store.RoleEditor)).Post("/import", app.importPortfolioHandler)
+				r.Get("/export", app.exportPortfolioHandler)
+
+				r.Route("/members", func(r chi.Router) {
+					r.Get("/", app.getPortfolioMembersHandler)
+					r.With(app.requirePortfolioRole(store.RoleOwner)).Post("/", app.invitePortfolioMemberHandler)
+					r.With(app.requirePortfolioRole(store.RoleOwner)).Patch("/{userID}", app.updatePortfolioMemberHandler)
+					r.Delete("/{userID}", app.removePortfolioMemberHandler)
+				})
 
 				r.Route("/stocks", func(r chi.Router) {
+					r.Use(app.requirePortfolioRole(store.RoleEditor))
 					r.Post("/", app.addStockHandler)
 					r.Put("/{symbol}", app.updateStockHandler)
 					r.Delete("/{symbol}", app.deleteStockHandler)
 				})
 
+				r.Route("/cash", func(r chi.Router) {
+					r.With(app.requirePortfolioRole(store.RoleEditor)).Post("/", app.createCashEntryHandler)
+					r.Get("/", app.getCashLedgerHandler)
+				})
+
+				r.Route("/transactions", func(r chi.Router) {
+					r.With(app.requirePortfolioRole(store.RoleEditor)).Post("/", app.createTransactionHandler)
+					r.Get("/", app.getTransactionsHandler)
+				})
+
 			})
 		})
 
@@ -174,6 +345,31 @@ func (app *application) run(mux *chi.Mux) error {
 
 	shutdown := make(chan error)
 
+	// Background workers stop when the server shuts down
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	var workers sync.WaitGroup
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		app.runPasswordResets(workersCtx)
+	}()
+	if app.alerts != nil {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			app.alerts.Run(workersCtx)
+		}()
+	}
+	if app.snapshots != nil {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			app.snapshots.Run(workersCtx)
+		}()
+	}
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -183,7 +379,12 @@ func (app *application) run(mux *chi.Mux) error {
 		defer cancel()
 
 		app.logger.Infow("signal caught", "addr", "signal", s.String())
-		shutdown <- srvr.Shutdown(ctx)
+		err := srvr.Shutdown(ctx)
+
+		stopWorkers()
+		workers.Wait()
+
+		shutdown <- err
 	}()
 
 	app.logger.Infow("server has started", "addr", app.config.addr, "env", app.config.env)
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -86,18 +89,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		Token: plainToken,
 	}
 
-	activationURL := fmt.Sprintf("%s/confirm/%s", app.config.frontEndURL, plainToken)
-
-	isProdEnv := app.config.env == "production"
-	vars := struct {
-		Username      string
-		ActivationURL string
-	}{
-		Username:      user.Username,
-		ActivationURL: activationURL,
-	}
-
-	status, err := app.mailer.Send(mailer.UserWelcomeTemplate, user.Username, user.Email, vars, !isProdEnv)
+	status, err := app.sendActivationEmail(user, plainToken)
 	if err != nil {
 		app.logger.Errorw("error sending welcome email", "error", err)
 		if err := app.store.Users.DeleteUser(r.Context(), user.ID); err != nil {
@@ -116,6 +108,21 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+func (app *application) sendActivationEmail(user *store.User, plainToken string) (int, error) {
+	activationURL := fmt.Sprintf("%s/confirm/%s", app.config.frontEndURL, plainToken)
+
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username      string
+		ActivationURL string
+	}{
+		Username:      user.Username,
+		ActivationURL: activationURL,
+	}
+
+	return app.mailer.Send(mailer.UserWelcomeTemplate, user.Username, user.Email, vars, !isProdEnv)
+}
+
 type CreateUserTokenPayload struct {
 	Email    string `json:"email" validate:"required,max=255"`
 	Password string `json:"password" validate:"required,min=8,max=16"`
@@ -124,14 +131,16 @@ type CreateUserTokenPayload struct {
 // createTokenHandler godoc
 //
 //	@Summary		Creates a token
-//	@Description	Creates a token for a user
+//	@Description	Creates a short-lived access token and a refresh token for a user. With two-factor authentication on, it returns a challenge token to complete at /authentication/token/mfa instead.
 //	@Tags			authentication
 //	@Accept			json
 //	@Produce		json
 //	@Param			payload	body		CreateUserTokenPayload	true	"User credentials"
 //	@Success		200		{object}	TokenResponse					"Token"
+//	@Success		202		{object}	MFAChallengeResponse			"Two-factor challenge"
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		429		{object}	error	"Too many wrong two-factor codes"
 //	@Failure		500		{object}	error
 //	@Router			/authentication/token [post]
 func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Request) {
@@ -169,27 +178,224 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	claims := jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(app.config.auth.token.expiry).Unix(),
-		"iat": time.Now().Unix(),
-		"nbf": time.Now().Unix(),
-		"iss": app.config.auth.token.iss,
-		"aud": app.config.auth.token.iss,
+	mfa, err := app.store.MFA.Get(ctx, user.ID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		app.internalServerError(w, r, err)
+		return
 	}
 
-	token, err := app.authenticator.GenerateToken(claims)
+	// With two-factor on, the password only earns a challenge for the second step
+	if mfa != nil && mfa.Enabled() {
+		locked, err := app.mfaLockedOut(ctx, user.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if locked {
+			app.rateLimitExceedResponse(w, r, app.config.auth.mfa.lockout.String())
+			return
+		}
+
+		challenge, err := app.newMFAChallenge(user.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if err := app.writeJsonResponse(w, http.StatusAccepted, challenge); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		return
+	}
+
+	tokens, err := app.issueTokens(ctx, user.ID, r)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	type TokenResponse struct {
-		Token string `json:"token"`
+	if err := app.writeJsonResponse(w, http.StatusOK, tokens); err != nil {
+		app.internalServerError(w, r, err)
+		return
 	}
+}
 
-	if err := app.writeJsonResponse(w, http.StatusOK, TokenResponse{Token: token}); err != nil {
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=255"`
+}
+
+// refreshTokenHandler godoc
+//
+//	@Summary		Refreshes a token
+//	@Description	Exchanges a refresh token for a new access token and refresh token. Each refresh token can be used once; using one again revokes every token issued from the same sign in.
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RefreshTokenPayload	true	"Refresh token"
+//	@Success		200		{object}	TokenResponse		"Token"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/refresh [post]
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+
+	err := readJson(w, r, &payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	plainToken, hashCode, err := newRefreshToken()
+	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
+
+	ctx := r.Context()
+
+	refreshToken, err := app.store.RefreshTokens.Rotate(ctx, hashToken(payload.RefreshToken), hashCode, app.config.auth.token.refreshExpiry)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrTokenReused):
+			app.logger.Warnw("refresh token reuse detected, session revoked", "user", refreshToken.UserID, "session", refreshToken.FamilyID)
+			// Access tokens already handed out for the session die with it
+			if err := app.revokeSession(ctx, refreshToken.FamilyID); err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+			app.unAuthorizedError(w, r, err)
+		case errors.Is(err, store.ErrNotFound):
+			app.unAuthorizedError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// Deactivation revokes every session, this still stops a refresh that raced it
+	_, err = app.store.Users.GetUserByID(ctx, refreshToken.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.unAuthorizedError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	accessToken, expiresAt, err := app.newAccessToken(refreshToken.UserID, refreshToken.FamilyID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tokens := TokenResponse{
+		Token:            accessToken,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     plainToken,
+		RefreshExpiresAt: refreshToken.Expiry,
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusOK, tokens); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type TokenResponse struct {
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// Start a session on the requesting device with its first tokens
+func (app *application) issueTokens(ctx context.Context, userID int64, r *http.Request) (*TokenResponse, error) {
+	plainToken, hashCode, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	userAgent := r.UserAgent()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	session := &store.Session{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		UserAgent: userAgent,
+		IPAddress: clientIP(r),
+		Expiry:    time.Now().Add(app.config.auth.token.refreshExpiry),
+	}
+	refreshToken := &store.RefreshToken{
+		Expiry: session.Expiry,
+	}
+
+	err = app.store.Sessions.Create(ctx, session, refreshToken, hashCode)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, expiresAt, err := app.newAccessToken(userID, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		Token:            accessToken,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     plainToken,
+		RefreshExpiresAt: refreshToken.Expiry,
+	}, nil
+}
+
+func (app *application) newAccessToken(userID int64, sessionID string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(app.config.auth.token.expiry)
+
+	claims := jwt.MapClaims{
+		"sub": userID,
+		"jti": uuid.New().String(),
+		"sid": sessionID,
+		"exp": expiresAt.Unix(),
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"iss": app.config.auth.token.iss,
+		"aud": app.config.auth.token.iss,
+	}
+
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// newRefreshToken returns an opaque token and the hash that is stored.
+func newRefreshToken() (string, string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", "", err
+	}
+
+	plainToken := base64.RawURLEncoding.EncodeToString(b)
+
+	return plainToken, hashToken(plainToken), nil
+}
+
+func hashToken(plainToken string) string {
+	hash := sha256.Sum256([]byte(plainToken))
+	return hex.EncodeToString(hash[:])
 }
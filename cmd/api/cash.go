@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+type CreateCashEntryPayload struct {
+	Currency    string  `json:"currency" validate:"required,iso4217"`
+	Type        string  `json:"type" validate:"required,oneof=deposit withdrawal dividend interest fee"`
+	Amount      float64 `json:"amount" validate:"required,gt=0"`
+	EntryDate   string  `json:"entry_date" validate:"required,datetime=2006-01-02"`
+	Description string  `json:"description" validate:"max=255"`
+}
+
+type CashLedger struct {
+	Balances []store.CashBalance `json:"balances"`
+	Entries  []store.CashEntry   `json:"entries"`
+}
+
+// CreateCashEntry godoc
+//
+//	@Summary		Records a cash movement
+//	@Description	Adds a deposit, withdrawal, dividend, interest or fee to the portfolio's cash ledger. Amount is always positive; withdrawals and fees reduce the balance.
+//	@Tags			portfolios
+//	@Accept			json
+//	@Produce		json
+//	@Param			portfolioID	path		int						true	"Portfolio ID"
+//	@Param			payload		body		CreateCashEntryPayload	true	"Cash entry payload"
+//	@Success		201			{object}	store.CashEntry
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/{portfolioID}/cash [post]
+func (app *application) createCashEntryHandler(w http.ResponseWriter, r *http.Request) {
+	portfolio := getPortfolioFromCtx(r)
+	user := getUserFromCtx(r)
+
+	var payload CreateCashEntryPayload
+	err := readJson(w, r, &payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	entryDate, err := time.Parse(store.DateLayout, payload.EntryDate)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	amount := payload.Amount
+	if payload.Type == store.CashWithdrawal || payload.Type == store.CashFee {
+		amount = -amount
+	}
+
+	entry := &store.CashEntry{
+		Currency:    payload.Currency,
+		Type:        payload.Type,
+		Amount:      amount,
+		EntryDate:   entryDate,
+		Description: payload.Description,
+	}
+
+	ctx := r.Context()
+
+	err = app.store.Cash.Record(ctx, portfolio.ID, user.ID, entry)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusCreated, entry)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetCashLedger godoc
+//
+//	@Summary		Fetches the cash ledger
+//	@Description	Returns the cash balance per currency and the ledger entries, optionally filtered by currency
+//	@Tags			portfolios
+//	@Produce		json
+//	@Param			portfolioID	path		int		true	"Portfolio ID"
+//	@Param			currency	query		string	false	"ISO 4217 currency code"
+//	@Success		200			{object}	CashLedger
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/{portfolioID}/cash [get]
+func (app *application) getCashLedgerHandler(w http.ResponseWriter, r *http.Request) {
+	portfolio := getPortfolioFromCtx(r)
+	currency := strings.ToUpper(r.URL.Query().Get("currency"))
+
+	ctx := r.Context()
+
+	balances, err := app.store.Cash.GetBalances(ctx, portfolio.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	entries, err := app.store.Cash.GetEntries(ctx, portfolio.ID, currency)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, CashLedger{
+		Balances: balances,
+		Entries:  entries,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+type CreateCorporateActionPayload struct {
+	Symbol    string  `json:"symbol" validate:"required,symbol"`
+	Type      string  `json:"type" validate:"required,oneof=dividend split ticker_change"`
+	ExDate    string  `json:"ex_date" validate:"required,datetime=2006-01-02"`
+	PayDate   string  `json:"pay_date" validate:"omitempty,datetime=2006-01-02"`
+	Amount    float64 `json:"amount" validate:"required_if=Type dividend,gte=0"`
+	Ratio     float64 `json:"ratio" validate:"required_if=Type split,gte=0"`
+	NewSymbol string  `json:"new_symbol" validate:"required_if=Type ticker_change,omitempty,symbol"`
+	Currency  string  `json:"currency" validate:"omitempty,iso4217"`
+}
+
+// CreateCorporateAction godoc
+//
+//	@Summary		Records a corporate action
+//	@Description	Records a dividend, split or ticker change and applies it to every portfolio holding the symbol before the ex-date. Dividends are paid on pay_date (defaults to ex_date) for the shares held and credited to cash; splits take the ratio, e.g. 2 for a 2-for-1 split. A ticker change also moves targets, watchlist items and price alerts to the new symbol. Portfolios the action cannot be applied to, such as ones whose ledger sells more than it holds, are left unchanged and listed in failures.
+//	@Tags			corporate-actions
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateCorporateActionPayload	true	"Corporate action payload"
+//	@Success		201		{object}	store.CorporateAction
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		BasicAuth
+//	@Router			/corporate-actions [post]
+func (app *application) createCorporateActionHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateCorporateActionPayload
+	err := readJson(w, r, &payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	exDate, err := time.Parse(store.DateLayout, payload.ExDate)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	payDate := exDate
+	if payload.PayDate != "" {
+		payDate, err = time.Parse(store.DateLayout, payload.PayDate)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+		if payDate.Before(exDate) {
+			app.badRequestError(w, r, errors.New("pay_date must not be before ex_date"))
+			return
+		}
+	}
+
+	action := &store.CorporateAction{
+		Symbol:   strings.ToUpper(strings.TrimSpace(payload.Symbol)),
+		Type:     payload.Type,
+		ExDate:   exDate,
+		PayDate:  payDate,
+		Currency: payload.Currency,
+	}
+
+	switch payload.Type {
+	case store.ActionDividend:
+		action.Amount = payload.Amount
+	case store.ActionSplit:
+		action.Ratio = payload.Ratio
+	case store.ActionTickerChange:
+		action.NewSymbol = strings.ToUpper(strings.TrimSpace(payload.NewSymbol))
+		if action.NewSymbol == action.Symbol {
+			app.badRequestError(w, r, errors.New("new_symbol must differ from symbol"))
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	err = app.store.CorporateActions.Create(ctx, action)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrDuplicateAction):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	for _, failure := range action.Failures {
+		app.logger.Warnw("corporate action skipped portfolio", "action", action.ID, "portfolio", failure.PortfolioID, "error", failure.Error)
+	}
+
+	err = app.writeJsonResponse(w, http.StatusCreated, action)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetCorporateActions godoc
+//
+//	@Summary		Lists corporate actions
+//	@Description	Lists recorded corporate actions, newest first, optionally for one symbol (matching either the old or new symbol of a ticker change)
+//	@Tags			corporate-actions
+//	@Produce		json
+//	@Param			symbol	query		string	false	"Stock Symbol"
+//	@Success		200		{array}		store.CorporateAction
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/corporate-actions [get]
+func (app *application) getCorporateActionsHandler(w http.ResponseWriter, r *http.Request) {
+	symbol := strings.ToUpper(r.URL.Query().Get("symbol"))
+
+	ctx := r.Context()
+
+	actions, err := app.store.CorporateActions.GetBySymbol(ctx, symbol)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, actions)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/analysis"
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+// GetDividendIncome godoc
+//
+//	@Summary		Fetches dividend income
+//	@Description	Lists dividends paid in the period with totals per symbol, total and annualized income in the base currency, and yield on market value and on cost. Defaults to the trailing twelve months.
+//	@Tags			portfolios
+//	@Produce		json
+//	@Param			portfolioID	path		int		true	"Portfolio ID"
+//	@Param			from		query		string	false	"Start date (YYYY-MM-DD)"
+//	@Param			to			query		string	false	"End date (YYYY-MM-DD)"
+//	@Success		200			{object}	analysis.DividendReport
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/{portfolioID}/dividends [get]
+func (app *application) getDividendIncomeHandler(w http.ResponseWriter, r *http.Request) {
+	portfolio := getPortfolioFromCtx(r)
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	drq := &store.DateRangeQuery{
+		From: today.AddDate(-1, 0, 1),
+		To:   today,
+	}
+
+	drq, err := drq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(drq)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	transactions, err := app.store.Transactions.GetByPortfolio(ctx, portfolio.ID, "")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	valuation, err := app.valuePortfolio(ctx, portfolio)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	currencies := []string{}
+	for _, t := range transactions {
+		if t.Type == store.TransactionDividend {
+			currencies = append(currencies, t.Currency)
+		}
+	}
+
+	rates, err := app.getFXRates(ctx, currencies, portfolio.BaseCurrency, drq.To)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	report := analysis.DividendIncome(valuation, transactions, drq.From, drq.To, rates)
+
+	err = app.writeJsonResponse(w, http.StatusOK, report)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -46,6 +46,13 @@ func (app *application) unAuthorizedError(w http.ResponseWriter, r *http.Request
 	writeJsonError(w, http.StatusUnauthorized, "unauthorized error")
 }
 
+func (app *application) forbiddenError(w http.ResponseWriter, r *http.Request, err error) {
+
+	app.logger.Warnw("forbidden", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	writeJsonError(w, http.StatusForbidden, "forbidden")
+}
+
 func (app *application) unAuthorizedBasicError(w http.ResponseWriter, r *http.Request, err error) {
 
 	app.logger.Errorw("unauthorized basic error: %s path: %s error: %s", r.Method, r.URL.Path, err.Error())
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	exportFormatCSV    = "csv"
+	exportFormatCSVBOM = "csv-bom"
+	exportFormatJSON   = "json"
+)
+
+var (
+	holdingsHeader     = []string{"portfolio_id", "portfolio_name", "symbol", "shares", "average_price", "currency"}
+	transactionsHeader = []string{"portfolio_id", "portfolio_name", "symbol", "type", "trade_date", "quantity", "price", "fees", "currency"}
+)
+
+type PortfolioExport struct {
+	Portfolio    *store.Portfolio    `json:"portfolio"`
+	Transactions []store.Transaction `json:"transactions"`
+}
+
+// ExportPortfolio godoc
+//
+//	@Summary		Exports a portfolio
+//	@Description	Downloads holdings or the transaction ledger as CSV (csv-bom adds a UTF-8 byte order mark so spreadsheet apps such as Excel read it as UTF-8), or the full portfolio as JSON. Exported transactions can be imported again.
+//	@Tags			portfolios
+//	@Produce		json
+//	@Produce		text/csv
+//	@Param			portfolioID	path		int		true	"Portfolio ID"
+//	@Param			format		query		string	false	"csv, csv-bom or json"		default(csv)
+//	@Param			kind		query		string	false	"holdings or transactions"	default(holdings)
+//	@Success		200			{object}	PortfolioExport
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/{portfolioID}/export [get]
+func (app *application) exportPortfolioHandler(w http.ResponseWriter, r *http.Request) {
+	portfolio := getPortfolioFromCtx(r)
+
+	format, kind, err := parseExportQuery(r, exportFormatCSV)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	transactions, err := app.store.Transactions.GetByPortfolio(ctx, portfolio.ID, "")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	filename := fmt.Sprintf("portfolio-%d-%s", portfolio.ID, kind)
+
+	if format == exportFormatJSON {
+		setAttachment(w, filename+".json")
+		err = app.writeJsonResponse(w, http.StatusOK, PortfolioExport{
+			Portfolio:    portfolio,
+			Transactions: transactions,
+		})
+		if err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	cw := startCSV(w, filename, format, kind)
+	err = writePortfolioRows(cw, portfolio, transactions, kind)
+	if err != nil {
+		app.logger.Errorw("error exporting portfolio", "portfolio", portfolio.ID, "error", err)
+	}
+}
+
+// ExportUser godoc
+//
+//	@Summary		Exports a user's account
+//	@Description	Streams every portfolio of the authenticated user with holdings and transactions as JSON, or holdings/transactions of all portfolios as CSV
+//	@Tags			users
+//	@Produce		json
+//	@Produce		text/csv
+//	@Param			userID	path	int		true	"User ID"
+//	@Param			format	query	string	false	"csv, csv-bom or json"		default(json)
+//	@Param			kind	query	string	false	"holdings or transactions"	default(holdings)
+//	@Success		200
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/export [get]
+func (app *application) exportUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if user == nil || user.ID != userID {
+		app.forbiddenError(w, r, errors.New("users can only export their own account"))
+		return
+	}
+
+	format, kind, err := parseExportQuery(r, exportFormatJSON)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	portfolioIDs, err := app.store.Portfolio.GetPortfolioIDs(ctx, user.ID, store.RoleViewer)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	filename := fmt.Sprintf("forseer-export-%d-%s", user.ID, time.Now().UTC().Format(store.DateLayout))
+
+	// Headers are sent before the portfolios are loaded, so later failures
+	// can only be logged and end the stream early.
+	var cw *csv.Writer
+	var enc *json.Encoder
+	if format == exportFormatJSON {
+		setAttachment(w, filename+".json")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		userJSON, err := json.Marshal(user)
+		if err != nil {
+			app.logger.Errorw("error exporting user", "user", user.ID, "error", err)
+			return
+		}
+		fmt.Fprintf(w, `{"data":{"user":%s,"exported_at":%q,"portfolios":[`, userJSON, time.Now().UTC().Format(time.RFC3339))
+		enc = json.NewEncoder(w)
+	} else {
+		cw = startCSV(w, filename, format, kind)
+	}
+
+	for i, portfolioID := range portfolioIDs {
+		portfolio, err := app.store.Portfolio.GetPortfolioByID(ctx, portfolioID, user.ID)
+		if err != nil {
+			app.logger.Errorw("error exporting portfolio", "portfolio", portfolioID, "error", err)
+			return
+		}
+
+		transactions, err := app.store.Transactions.GetByPortfolio(ctx, portfolioID, "")
+		if err != nil {
+			app.logger.Errorw("error exporting portfolio", "portfolio", portfolioID, "error", err)
+			return
+		}
+
+		if enc != nil {
+			if i > 0 {
+				w.Write([]byte(","))
+			}
+			err = enc.Encode(PortfolioExport{Portfolio: portfolio, Transactions: transactions})
+		} else {
+			err = writePortfolioRows(cw, portfolio, transactions, kind)
+		}
+		if err != nil {
+			app.logger.Errorw("error exporting portfolio", "portfolio", portfolioID, "error", err)
+			return
+		}
+
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+
+	if enc != nil {
+		w.Write([]byte("]}}\n"))
+	}
+}
+
+func parseExportQuery(r *http.Request, defaultFormat string) (string, string, error) {
+	qs := r.URL.Query()
+
+	format := qs.Get("format")
+	switch format {
+	case "":
+		format = defaultFormat
+	case exportFormatCSV, exportFormatCSVBOM, exportFormatJSON:
+	default:
+		return "", "", errors.New("format must be one of csv, csv-bom, json")
+	}
+
+	kind := qs.Get("kind")
+	switch kind {
+	case "":
+		kind = "holdings"
+	case "holdings", "transactions":
+	default:
+		return "", "", errors.New("kind must be one of holdings, transactions")
+	}
+
+	return format, kind, nil
+}
+
+func setAttachment(w http.ResponseWriter, filename string) {
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+}
+
+func startCSV(w http.ResponseWriter, filename, format, kind string) *csv.Writer {
+	setAttachment(w, filename+".csv")
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	if format == exportFormatCSVBOM {
+		w.Write([]byte("\ufeff"))
+	}
+
+	cw := csv.NewWriter(w)
+	if kind == "transactions" {
+		cw.Write(transactionsHeader)
+	} else {
+		cw.Write(holdingsHeader)
+	}
+
+	return cw
+}
+
+func writePortfolioRows(cw *csv.Writer, portfolio *store.Portfolio, transactions []store.Transaction, kind string) error {
+	id := strconv.FormatInt(portfolio.ID, 10)
+
+	if kind == "transactions" {
+		for _, t := range transactions {
+			err := cw.Write([]string{
+				id,
+				portfolio.Name,
+				t.Symbol,
+				t.Type,
+				t.TradeDate.Format(store.DateLayout),
+				formatFloat(t.Quantity),
+				formatFloat(t.Price),
+				formatFloat(t.Fees),
+				t.Currency,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		for _, stock := range portfolio.Stocks {
+			err := cw.Write([]string{
+				id,
+				portfolio.Name,
+				stock.Symbol,
+				formatFloat(stock.Shares),
+				formatFloat(stock.AveragePrice),
+				stock.Currency,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/analysis"
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+// GetPortfolioExposure godoc
+//
+//	@Summary		Fetches portfolio exposure
+//	@Description	Aggregates the portfolio's market value and cash by sector, asset class, country and currency in its base currency, using the securities reference data
+//	@Tags			portfolios
+//	@Produce		json
+//	@Param			portfolioID	path		int	true	"Portfolio ID"
+//	@Success		200			{object}	analysis.Exposure
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/{portfolioID}/exposure [get]
+func (app *application) getPortfolioExposureHandler(w http.ResponseWriter, r *http.Request) {
+	portfolio := getPortfolioFromCtx(r)
+
+	ctx := r.Context()
+
+	exposure, err := app.getExposure(ctx, []*store.Portfolio{portfolio}, portfolio.BaseCurrency)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, exposure)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetUserExposure godoc
+//
+//	@Summary		Fetches exposure across portfolios
+//	@Description	Aggregates market value and cash of all the portfolios the user owns by sector, asset class, country and currency. Values are in the given currency, defaulting to the portfolios' shared base currency or USD.
+//	@Tags			portfolios
+//	@Produce		json
+//	@Param			currency	query		string	false	"Reporting currency (ISO 4217)"
+//	@Success		200			{object}	analysis.Exposure
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/exposure [get]
+func (app *application) getUserExposureHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	currency := strings.ToUpper(r.URL.Query().Get("currency"))
+	if currency != "" {
+		err := Validate.Var(currency, "iso4217")
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	portfolios, err := app.getUserPortfolios(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if currency == "" {
+		currency = commonBaseCurrency(portfolios)
+	}
+
+	exposure, err := app.getExposure(ctx, portfolios, currency)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, exposure)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) getExposure(ctx context.Context, portfolios []*store.Portfolio, currency string) (*analysis.Exposure, error) {
+	valuations := make([]*analysis.Valuation, 0, len(portfolios))
+	bases := []string{}
+	symbols := []string{}
+
+	for _, portfolio := range portfolios {
+		valuation, err := app.valuePortfolio(ctx, portfolio)
+		if err != nil {
+			return nil, err
+		}
+		valuations = append(valuations, valuation)
+		bases = append(bases, portfolio.BaseCurrency)
+
+		for _, stock := range portfolio.Stocks {
+			symbols = append(symbols, stock.Symbol)
+		}
+	}
+
+	securities, err := app.store.Securities.GetBySymbols(ctx, symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	rates, err := app.getFXRates(ctx, bases, currency, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return analysis.Exposures(valuations, securities, currency, rates), nil
+}
+
+// Load every portfolio the user owns, Check Cache or go db
+func (app *application) getUserPortfolios(ctx context.Context, userID int64) ([]*store.Portfolio, error) {
+	ids, err := app.store.Portfolio.GetPortfolioIDs(ctx, userID, store.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	portfolios := make([]*store.Portfolio, 0, len(ids))
+	for _, id := range ids {
+		portfolio, err := app.getPortfolio(ctx, id, userID)
+		if err != nil {
+			return nil, err
+		}
+		portfolios = append(portfolios, portfolio)
+	}
+
+	return portfolios, nil
+}
+
+// The base currency shared by all portfolios, or the default when they differ
+func commonBaseCurrency(portfolios []*store.Portfolio) string {
+	if len(portfolios) == 0 {
+		return store.DefaultCurrency
+	}
+
+	currency := portfolios[0].BaseCurrency
+	for _, portfolio := range portfolios[1:] {
+		if portfolio.BaseCurrency != currency {
+			return store.DefaultCurrency
+		}
+	}
+
+	return currency
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/snapshots"
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+type PortfolioHistory struct {
+	PortfolioID int64            `json:"portfolio_id"`
+	Interval    string           `json:"interval"`
+	From        time.Time        `json:"from"`
+	To          time.Time        `json:"to"`
+	Points      []store.Snapshot `json:"points"`
+}
+
+// GetPortfolioHistory godoc
+//
+//	@Summary		Fetches the historical value of a portfolio
+//	@Description	Lists the end of day snapshots of a portfolio between from and to (YYYY-MM-DD), defaulting to the last year. With interval week or month only the last snapshot of each period is returned. Each point's values are in its own currency, the base currency the portfolio had when the snapshot was taken. Points listing unpriced symbols or unconverted currencies are incomplete, as those holdings are left out of their values.
+//	@Tags			portfolios
+//	@Produce		json
+//	@Param			portfolioID	path		int		true	"Portfolio ID"
+//	@Param			from		query		string	false	"Start date (YYYY-MM-DD)"
+//	@Param			to			query		string	false	"End date (YYYY-MM-DD)"
+//	@Param			interval	query		string	false	"day, week or month"	default(day)
+//	@Success		200			{object}	PortfolioHistory
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/{portfolioID}/history [get]
+func (app *application) getPortfolioHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	portfolio := getPortfolioFromCtx(r)
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	drq := &store.DateRangeQuery{
+		From: today.AddDate(-1, 0, 0),
+		To:   today,
+	}
+
+	drq, err := drq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(drq)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = snapshots.IntervalDay
+	}
+
+	ctx := r.Context()
+
+	points, err := app.store.Snapshots.GetRange(ctx, portfolio.ID, drq.From, drq.To)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	points, err = snapshots.Resample(points, interval)
+	if err != nil {
+		switch {
+		case errors.Is(err, snapshots.ErrInvalidInterval):
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	history := PortfolioHistory{
+		PortfolioID: portfolio.ID,
+		Interval:    interval,
+		From:        drq.From,
+		To:          drq.To,
+		Points:      points,
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, history)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
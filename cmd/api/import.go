@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/importer"
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+const maxImportSize = 10 << 20 //10mb
+
+// ImportPortfolio godoc
+//
+//	@Summary		Imports holdings or transactions from CSV
+//	@Description	Parses a CSV upload into ledger transactions. mapping is a JSON object from field name (symbol, type, trade_date, quantity, price, fees, currency, shares, average_price) to CSV header. With dry_run=true only the per-row report is returned; otherwise every row is committed or none is.
+//	@Tags			portfolios
+//	@Accept			mpfd
+//	@Produce		json
+//	@Param			portfolioID	path		int		true	"Portfolio ID"
+//	@Param			file		formData	file	true	"CSV file"
+//	@Param			kind		formData	string	false	"transactions or holdings"	default(transactions)
+//	@Param			mapping		formData	string	false	"JSON column mapping, e.g. {\"symbol\":\"Ticker\"}"
+//	@Param			date_format	formData	string	false	"Go date layout"	default(2006-01-02)
+//	@Param			dry_run		formData	bool	false	"Validate without committing"
+//	@Success		200			{object}	importer.Report
+//	@Success		201			{object}	importer.Report
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		422			{object}	importer.Report
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/{portfolioID}/import [post]
+func (app *application) importPortfolioHandler(w http.ResponseWriter, r *http.Request) {
+	portfolio := getPortfolioFromCtx(r)
+	user := getUserFromCtx(r)
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	err := r.ParseMultipartForm(maxImportSize)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	opts := importer.Options{
+		Kind:       r.FormValue("kind"),
+		Mapping:    map[string]string{},
+		DateLayout: r.FormValue("date_format"),
+		Today:      time.Now().UTC().Truncate(24 * time.Hour),
+	}
+	if opts.Kind == "" {
+		opts.Kind = importer.KindTransactions
+	}
+
+	if mapping := r.FormValue("mapping"); mapping != "" {
+		err = json.Unmarshal([]byte(mapping), &opts.Mapping)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+	}
+
+	dryRun := false
+	if v := r.FormValue("dry_run"); v != "" {
+		dryRun, err = strconv.ParseBool(v)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+	}
+
+	report, err := importer.Parse(file, opts)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	report.DryRun = dryRun
+
+	ctx := r.Context()
+
+	// Replay the existing ledger with the new rows to catch oversold positions
+	existing, err := app.store.Transactions.GetByPortfolio(ctx, portfolio.ID, "")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	for _, t := range report.Transactions() {
+		existing = append(existing, *t)
+	}
+	_, _, err = store.MatchLots(existing, portfolio.CostBasisMethod)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+
+	symbols := []string{}
+	for _, t := range report.Transactions() {
+		symbols = append(symbols, t.Symbol)
+	}
+	err = app.checkSymbols(ctx, symbols...)
+	if err != nil {
+		if !errors.Is(err, store.ErrUnknownSymbol) {
+			app.internalServerError(w, r, err)
+			return
+		}
+		report.Errors = append(report.Errors, err.Error())
+	}
+
+	if dryRun || !report.Valid() {
+		status := http.StatusOK
+		if !report.Valid() && !dryRun {
+			status = http.StatusUnprocessableEntity
+		}
+
+		err = app.writeJsonResponse(w, status, report)
+		if err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.store.Transactions.Import(ctx, portfolio.ID, user.ID, report.Transactions())
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		case errors.Is(err, store.ErrInsufficientShares), errors.Is(err, store.ErrCurrencyMismatch):
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	report.Committed = true
+
+	err = app.writeJsonResponse(w, http.StatusCreated, report)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/ecetinerdem/forseerv2/internal/marketdata"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -11,6 +12,12 @@ var Validate *validator.Validate
 
 func init() {
 	Validate = validator.New(validator.WithRequiredStructEnabled())
+
+	// symbol accepts ticker symbols in any case, e.g. aapl or BRK.B
+	Validate.RegisterValidation("symbol", func(fl validator.FieldLevel) bool {
+		_, err := marketdata.NormalizeSymbol(fl.Field().String())
+		return err == nil
+	})
 }
 
 func writeJson(w http.ResponseWriter, status int, data any) error {
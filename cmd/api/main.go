@@ -2,14 +2,19 @@ package main
 
 import (
 	"expvar"
+	"fmt"
 	"runtime"
 	"time"
 
+	"github.com/ecetinerdem/forseerv2/internal/alerts"
 	"github.com/ecetinerdem/forseerv2/internal/auth"
 	"github.com/ecetinerdem/forseerv2/internal/db"
 	"github.com/ecetinerdem/forseerv2/internal/env"
+	"github.com/ecetinerdem/forseerv2/internal/fx"
 	"github.com/ecetinerdem/forseerv2/internal/mailer"
+	"github.com/ecetinerdem/forseerv2/internal/marketdata"
 	"github.com/ecetinerdem/forseerv2/internal/ratelimiter"
+	"github.com/ecetinerdem/forseerv2/internal/snapshots"
 	"github.com/ecetinerdem/forseerv2/internal/store"
 	"github.com/ecetinerdem/forseerv2/internal/store/cache"
 	"github.com/redis/go-redis/v9"
@@ -34,6 +39,7 @@ const version = "1.9.0"
 // @in							header
 // @name						Authorization
 // @description
+// @securityDefinitions.basic	BasicAuth
 func main() {
 
 	cfg := config{
@@ -67,16 +73,47 @@ func main() {
 				pass: env.GetString("AUTH_BASIC_PASS", ""),
 			},
 			token: tokenConfig{
-				secret: env.GetString("AUTH_TOKEN_SECRET", ""),
-				expiry: time.Hour * 24 * 3,
-				iss:    env.GetString("AUTH_TOKEN_ISS", "forseer"),
+				secret:        env.GetString("AUTH_TOKEN_SECRET", ""),
+				expiry:        env.GetDuration("AUTH_TOKEN_EXP", "15m"),
+				refreshExpiry: env.GetDuration("AUTH_REFRESH_TOKEN_EXP", "720h"),
+				iss:           env.GetString("AUTH_TOKEN_ISS", "forseer"),
 			},
+			mfa: mfaConfig{
+				issuer:          env.GetString("AUTH_MFA_ISSUER", "Forseer"),
+				challengeExpiry: env.GetDuration("AUTH_MFA_CHALLENGE_EXP", "5m"),
+				lockout:         env.GetDuration("AUTH_MFA_LOCKOUT", "15m"),
+			},
+			resetExpiry: env.GetDuration("AUTH_PASSWORD_RESET_EXP", "1h"),
 		},
 		rateLimiter: ratelimiter.Config{
 			RequestPerTimeFrame: env.GetInt("RATELIMITER_REQUESTS_COUNT", 20),
 			TimeFrame:           time.Second * 5,
 			Enabled:             env.GetBool("RATELIMITER_REQUESTS_COUNT", true),
 		},
+		marketData: marketDataConfig{
+			provider: env.GetString("MARKETDATA_PROVIDER", "file"),
+			dataDir:  env.GetString("MARKETDATA_DIR", "./data/prices"),
+			baseURL:  env.GetString("MARKETDATA_URL", ""),
+			apiKey:   env.GetString("MARKETDATA_API_KEY", ""),
+		},
+		analysis: analysisConfig{
+			riskFreeRate: env.GetFloat("ANALYSIS_RISK_FREE_RATE", 0.0),
+		},
+		securities: securitiesConfig{
+			validate: env.GetBool("SECURITIES_VALIDATE", true),
+		},
+		alerts: alertsConfig{
+			enabled:  env.GetBool("ALERTS_ENABLED", true),
+			interval: env.GetDuration("ALERTS_INTERVAL", "5m"),
+		},
+		snapshots: snapshotsConfig{
+			enabled:  env.GetBool("SNAPSHOTS_ENABLED", true),
+			interval: env.GetDuration("SNAPSHOTS_INTERVAL", "24h"),
+			lookback: env.GetInt("SNAPSHOTS_LOOKBACK_DAYS", 7),
+		},
+		fx: fxConfig{
+			pivot: env.GetString("FX_PIVOT_CURRENCY", fx.DefaultPivot),
+		},
 	}
 
 	//Logger
@@ -118,14 +155,47 @@ func main() {
 
 	cacheStorage := cache.NewRedisStorage(rdb)
 
+	//Market Data
+	var marketDataProvider marketdata.Provider
+	switch cfg.marketData.provider {
+	case "http":
+		marketDataProvider = marketdata.NewHTTPProvider(cfg.marketData.baseURL, cfg.marketData.apiKey)
+	default:
+		marketDataProvider = marketdata.NewFileProvider(cfg.marketData.dataDir)
+	}
+	logger.Infow("market data provider configured", "provider", cfg.marketData.provider)
+
+	//FX
+	fxConverter := fx.NewConverter(store.FXRates, cfg.fx.pivot)
+
 	app := &application{
-		config:        cfg,
-		store:         store,
-		cacheStorage:  cacheStorage,
-		logger:        logger,
-		mailer:        mailer,
-		authenticator: JWTAuthenticator,
-		rateLimiter:   rateLimiter,
+		config:         cfg,
+		store:          store,
+		cacheStorage:   cacheStorage,
+		logger:         logger,
+		mailer:         mailer,
+		authenticator:  JWTAuthenticator,
+		rateLimiter:    rateLimiter,
+		marketData:     marketDataProvider,
+		fx:             fxConverter,
+		passwordResets: make(chan string, passwordResetQueueSize),
+	}
+
+	//Alerts
+	if cfg.alerts.enabled {
+		app.alerts = alerts.NewEvaluator(store, marketDataProvider, fxConverter, mailer, logger, alerts.Config{
+			Interval:  cfg.alerts.interval,
+			AlertsURL: fmt.Sprintf("%s/alerts", cfg.frontEndURL),
+			IsSandbox: cfg.env != "production",
+		})
+	}
+
+	//Snapshots
+	if cfg.snapshots.enabled {
+		app.snapshots = snapshots.NewRecorder(store, marketDataProvider, fxConverter, logger, snapshots.Config{
+			Interval: cfg.snapshots.interval,
+			Lookback: cfg.snapshots.lookback,
+		})
 	}
 
 	// Metrics
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ecetinerdem/forseerv2/internal/mailer"
+	"github.com/ecetinerdem/forseerv2/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type InviteMemberPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+	Role  string `json:"role" validate:"required,oneof=viewer editor owner"`
+}
+
+type UpdateMemberPayload struct {
+	Role string `json:"role" validate:"required,oneof=viewer editor owner"`
+}
+
+// GetPortfolioMembers godoc
+//
+//	@Summary		Lists portfolio members
+//	@Description	Lists the users a portfolio is shared with and their roles
+//	@Tags			portfolios
+//	@Produce		json
+//	@Param			portfolioID	path		int	true	"Portfolio ID"
+//	@Success		200			{array}		store.Member
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/{portfolioID}/members [get]
+func (app *application) getPortfolioMembersHandler(w http.ResponseWriter, r *http.Request) {
+	portfolio := getPortfolioFromCtx(r)
+
+	ctx := r.Context()
+
+	members, err := app.store.Members.GetByPortfolio(ctx, portfolio.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, members)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// InvitePortfolioMember godoc
+//
+//	@Summary		Invites a user to a portfolio
+//	@Description	Emails an invitation to share the portfolio with the given role. The invitee accepts it while signed in with that email address. Inviting the same email again replaces the pending invitation. Owners only.
+//	@Tags			portfolios
+//	@Accept			json
+//	@Produce		json
+//	@Param			portfolioID	path		int					true	"Portfolio ID"
+//	@Param			payload		body		InviteMemberPayload	true	"Invitation payload"
+//	@Success		201			{object}	store.PortfolioInvitation
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/{portfolioID}/members [post]
+func (app *application) invitePortfolioMemberHandler(w http.ResponseWriter, r *http.Request) {
+	portfolio := getPortfolioFromCtx(r)
+	user := getUserFromCtx(r)
+
+	var payload InviteMemberPayload
+	err := readJson(w, r, &payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	invitation := &store.PortfolioInvitation{
+		PortfolioID: portfolio.ID,
+		Email:       strings.TrimSpace(payload.Email),
+		Role:        payload.Role,
+		InvitedBy:   user.ID,
+	}
+
+	ctx := r.Context()
+
+	// Address existing users by name, anyone else by email
+	username := invitation.Email
+	invitee, err := app.store.Users.GetByEmail(ctx, invitation.Email)
+	switch {
+	case err == nil:
+		username = invitee.Username
+	case !errors.Is(err, store.ErrNotFound):
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	plainToken := uuid.New().String()
+	hash := sha256.Sum256([]byte(plainToken))
+	hashCode := hex.EncodeToString(hash[:])
+	err = app.store.Members.Invite(ctx, invitation, hashCode, app.config.mail.expiry)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrAlreadyMember):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username      string
+		InvitedBy     string
+		PortfolioName string
+		Role          string
+		InvitationURL string
+		Expiry        string
+	}{
+		Username:      username,
+		InvitedBy:     user.Username,
+		PortfolioName: portfolio.Name,
+		Role:          invitation.Role,
+		InvitationURL: fmt.Sprintf("%s/portfolios/invitations/%s", app.config.frontEndURL, plainToken),
+		Expiry:        invitation.Expiry.Format("2006-01-02 15:04 MST"),
+	}
+
+	status, err := app.mailer.Send(mailer.PortfolioInvitationTemplate, username, invitation.Email, vars, !isProdEnv)
+	if err != nil {
+		app.logger.Errorw("error sending portfolio invitation email", "error", err)
+		if err := app.store.Members.DeleteInvitation(ctx, hashCode); err != nil {
+			app.logger.Errorw("error deleting portfolio invitation after failed email", "error", err)
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("Email sent", "status code", status)
+
+	err = app.writeJsonResponse(w, http.StatusCreated, invitation)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// AcceptPortfolioInvitation godoc
+//
+//	@Summary		Accepts a portfolio invitation
+//	@Description	Adds the authenticated user to the portfolio of an invitation sent to their email address
+//	@Tags			portfolios
+//	@Produce		json
+//	@Param			token	path		string	true	"Invitation token"
+//	@Success		200		{object}	store.Member
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/invitations/{token} [put]
+func (app *application) acceptPortfolioInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	token := chi.URLParam(r, "token")
+
+	ctx := r.Context()
+
+	member, err := app.store.Members.Accept(ctx, token, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		case errors.Is(err, store.ErrAlreadyMember):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, member)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// UpdatePortfolioMember godoc
+//
+//	@Summary		Changes a member's role
+//	@Description	Changes the role of a portfolio member. The last owner cannot be demoted. Owners only.
+//	@Tags			portfolios
+//	@Accept			json
+//	@Param			portfolioID	path	int					true	"Portfolio ID"
+//	@Param			userID		path	int					true	"User ID"
+//	@Param			payload		body	UpdateMemberPayload	true	"Role payload"
+//	@Success		204			"Role updated"
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/{portfolioID}/members/{userID} [patch]
+func (app *application) updatePortfolioMemberHandler(w http.ResponseWriter, r *http.Request) {
+	portfolio := getPortfolioFromCtx(r)
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	var payload UpdateMemberPayload
+	err = readJson(w, r, &payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	err = app.store.Members.UpdateRole(ctx, portfolio.ID, userID, payload.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		case errors.Is(err, store.ErrLastOwner):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemovePortfolioMember godoc
+//
+//	@Summary		Removes a member from a portfolio
+//	@Description	Owners can remove any member and members can remove themselves. The last owner cannot be removed.
+//	@Tags			portfolios
+//	@Param			portfolioID	path	int	true	"Portfolio ID"
+//	@Param			userID		path	int	true	"User ID"
+//	@Success		204			"Member removed"
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/{portfolioID}/members/{userID} [delete]
+func (app *application) removePortfolioMemberHandler(w http.ResponseWriter, r *http.Request) {
+	portfolio := getPortfolioFromCtx(r)
+	user := getUserFromCtx(r)
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if userID != user.ID && !store.HasRole(portfolio.Role, store.RoleOwner) {
+		app.forbiddenError(w, r, fmt.Errorf("portfolio requires %s role", store.RoleOwner))
+		return
+	}
+
+	ctx := r.Context()
+
+	err = app.store.Members.Remove(ctx, portfolio.ID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		case errors.Is(err, store.ErrLastOwner):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,630 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/auth"
+	"github.com/ecetinerdem/forseerv2/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	mfaChallengeType  = "mfa"
+	recoveryCodeCount = 10
+	// Wrong codes a challenge token survives before it is revoked
+	maxMFAAttempts = 5
+	// Wrong codes a user may enter within the lockout window, across challenges
+	maxMFAUserFailures = 10
+)
+
+var errInvalidMFACode = errors.New("invalid two-factor code")
+
+type MFACodePayload struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+type MFAChallengePayload struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required,max=32"`
+}
+
+type MFAEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type MFAChallengeResponse struct {
+	MFARequired    bool      `json:"mfa_required"`
+	ChallengeToken string    `json:"challenge_token"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// EnrollMFA godoc
+//
+//	@Summary		Starts two-factor enrollment
+//	@Description	Creates a TOTP secret and returns it with an otpauth provisioning URI to scan into an authenticator app. Two-factor stays off until a code is verified. Enrolling again replaces an unverified secret.
+//	@Tags			authentication
+//	@Produce		json
+//	@Success		200	{object}	MFAEnrollment
+//	@Failure		401	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/mfa/enroll [post]
+func (app *application) enrollMFAHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	err = app.store.MFA.Enroll(ctx, user.ID, secret)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrMFAEnabled):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	enrollment := MFAEnrollment{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(secret, app.config.auth.mfa.issuer, user.Email),
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, enrollment)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// VerifyMFA godoc
+//
+//	@Summary		Turns on two-factor authentication
+//	@Description	Verifies a code from the authenticator app against the enrolled secret and turns two-factor on. The response holds single-use recovery codes, which are shown only once.
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		MFACodePayload	true	"Authenticator code"
+//	@Success		200		{object}	RecoveryCodesResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/mfa/verify [post]
+func (app *application) verifyMFAHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	var payload MFACodePayload
+
+	err := readJson(w, r, &payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	mfa, err := app.store.MFA.Get(ctx, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if mfa.Enabled() {
+		app.conflictError(w, r, store.ErrMFAEnabled)
+		return
+	}
+
+	step, ok := auth.ValidateTOTP(mfa.Secret, payload.Code, time.Now())
+	if !ok {
+		app.badRequestError(w, r, errInvalidMFACode)
+		return
+	}
+
+	codes, hashedCodes, err := newRecoveryCodes()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.store.MFA.Enable(ctx, user.ID, step, hashedCodes)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrMFAEnabled):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// RegenerateRecoveryCodes godoc
+//
+//	@Summary		Replaces the recovery codes
+//	@Description	Issues a new set of recovery codes after checking an authenticator code. Earlier recovery codes stop working.
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		MFACodePayload	true	"Authenticator code"
+//	@Success		200		{object}	RecoveryCodesResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/mfa/recovery-codes [post]
+func (app *application) regenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	var payload MFACodePayload
+
+	err := readJson(w, r, &payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	mfa, err := app.getEnabledMFA(ctx, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.checkTOTP(ctx, mfa, payload.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidMFACode):
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	codes, hashedCodes, err := newRecoveryCodes()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.store.MFA.RegenerateRecoveryCodes(ctx, user.ID, hashedCodes)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// DisableMFA godoc
+//
+//	@Summary		Turns off two-factor authentication
+//	@Description	Removes the TOTP secret and recovery codes after checking an authenticator or recovery code
+//	@Tags			authentication
+//	@Accept			json
+//	@Param			payload	body	MFACodePayload	true	"Authenticator or recovery code"
+//	@Success		204		"Two-factor turned off"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/mfa/disable [post]
+func (app *application) disableMFAHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	var payload MFACodePayload
+
+	err := readJson(w, r, &payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	mfa, err := app.getEnabledMFA(ctx, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.checkMFACode(ctx, mfa, payload.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidMFACode):
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.store.MFA.Disable(ctx, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyMFAChallenge godoc
+//
+//	@Summary		Completes a two-factor sign in
+//	@Description	Exchanges the challenge token returned by /authentication/token and an authenticator or recovery code for an access token and a refresh token. Each challenge token signs in once and is revoked after five wrong codes. Ten wrong codes across challenges lock two-factor sign in for AUTH_MFA_LOCKOUT.
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		MFAChallengePayload	true	"Challenge token and code"
+//	@Success		200		{object}	TokenResponse		"Token"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/token/mfa [post]
+func (app *application) verifyMFAChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	var payload MFAChallengePayload
+
+	err := readJson(w, r, &payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	jwtToken, err := app.authenticator.ValidateToken(payload.ChallengeToken)
+	if err != nil {
+		app.unAuthorizedError(w, r, err)
+		return
+	}
+
+	claims, ok := jwtToken.Claims.(jwt.MapClaims)
+	if !ok || claims == nil {
+		app.unAuthorizedError(w, r, fmt.Errorf("invalid token claims"))
+		return
+	}
+
+	tokenType, _ := claims["typ"].(string)
+	challengeID, _ := claims["jti"].(string)
+	if tokenType != mfaChallengeType || challengeID == "" {
+		app.unAuthorizedError(w, r, fmt.Errorf("not a two-factor challenge token"))
+		return
+	}
+
+	userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+	if err != nil {
+		app.unAuthorizedError(w, r, err)
+		return
+	}
+
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil {
+		app.unAuthorizedError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	revoked, err := app.isRevoked(ctx, challengeID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if revoked {
+		app.unAuthorizedError(w, r, fmt.Errorf("challenge already used or revoked"))
+		return
+	}
+
+	// The account may have been deactivated since the password was checked
+	user, err := app.store.Users.GetUserByID(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.unAuthorizedError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	mfa, err := app.getEnabledMFA(ctx, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.unAuthorizedError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	locked, err := app.mfaLockedOut(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if locked {
+		app.rateLimitExceedResponse(w, r, app.config.auth.mfa.lockout.String())
+		return
+	}
+
+	err = app.checkMFACode(ctx, mfa, payload.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidMFACode):
+			if err := app.failMFAChallenge(ctx, challengeID, user.ID, expiresAt.Time); err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+			app.unAuthorizedError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.revoke(ctx, challengeID, expiresAt.Time)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.resetAttempts(ctx, mfaUserKey(user.ID))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tokens, err := app.issueTokens(ctx, user.ID, r)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusOK, tokens); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// failMFAChallenge counts a wrong code against the challenge and the user. The
+// challenge is revoked once maxMFAAttempts have failed, so the user has to
+// sign in again, and the user is locked out of two-factor sign in after
+// maxMFAUserFailures within the lockout window.
+func (app *application) failMFAChallenge(ctx context.Context, challengeID string, userID int64, expiry time.Time) error {
+	userFailures, err := app.countAttempt(ctx, mfaUserKey(userID), time.Now().Add(app.config.auth.mfa.lockout))
+	if err != nil {
+		return err
+	}
+
+	attempts, err := app.countAttempt(ctx, challengeID, expiry)
+	if err != nil {
+		return err
+	}
+
+	if attempts < maxMFAAttempts && userFailures < maxMFAUserFailures {
+		return nil
+	}
+
+	app.logger.Warnw("two-factor challenge revoked after failed attempts", "challenge", challengeID, "user", userID)
+
+	return app.revoke(ctx, challengeID, expiry)
+}
+
+// mfaLockedOut reports whether the user entered too many wrong codes within
+// the lockout window.
+func (app *application) mfaLockedOut(ctx context.Context, userID int64) (bool, error) {
+	failures, err := app.getAttempts(ctx, mfaUserKey(userID))
+	if err != nil {
+		return false, err
+	}
+
+	return failures >= maxMFAUserFailures, nil
+}
+
+func mfaUserKey(userID int64) string {
+	return fmt.Sprintf("mfa-user-%d", userID)
+}
+
+// Check Cache or go db
+func (app *application) countAttempt(ctx context.Context, id string, expiry time.Time) (int64, error) {
+	if !app.config.redisCfg.enabled {
+		return app.store.Attempts.Increment(ctx, id, expiry)
+	}
+
+	return app.cacheStorage.Attempts.Increment(ctx, id, expiry)
+}
+
+// Check Cache or go db
+func (app *application) getAttempts(ctx context.Context, id string) (int64, error) {
+	if !app.config.redisCfg.enabled {
+		return app.store.Attempts.Get(ctx, id)
+	}
+
+	return app.cacheStorage.Attempts.Get(ctx, id)
+}
+
+// Check Cache or go db
+func (app *application) resetAttempts(ctx context.Context, id string) error {
+	if !app.config.redisCfg.enabled {
+		return app.store.Attempts.Reset(ctx, id)
+	}
+
+	return app.cacheStorage.Attempts.Reset(ctx, id)
+}
+
+// newMFAChallenge returns a short-lived token proving the password of the
+// user was checked. It carries no session, so it is never an access token.
+func (app *application) newMFAChallenge(userID int64) (*MFAChallengeResponse, error) {
+	now := time.Now()
+	expiresAt := now.Add(app.config.auth.mfa.challengeExpiry)
+
+	claims := jwt.MapClaims{
+		"sub": userID,
+		"jti": uuid.New().String(),
+		"typ": mfaChallengeType,
+		"exp": expiresAt.Unix(),
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"iss": app.config.auth.token.iss,
+		"aud": app.config.auth.token.iss,
+	}
+
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MFAChallengeResponse{
+		MFARequired:    true,
+		ChallengeToken: token,
+		ExpiresAt:      expiresAt,
+	}, nil
+}
+
+func (app *application) getEnabledMFA(ctx context.Context, userID int64) (*store.MFA, error) {
+	mfa, err := app.store.MFA.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !mfa.Enabled() {
+		return nil, store.ErrNotFound
+	}
+
+	return mfa, nil
+}
+
+// checkMFACode accepts an authenticator code or an unused recovery code.
+func (app *application) checkMFACode(ctx context.Context, mfa *store.MFA, code string) error {
+	if len(code) == auth.TOTPDigits {
+		return app.checkTOTP(ctx, mfa, code)
+	}
+
+	err := app.store.MFA.UseRecoveryCode(ctx, mfa.UserID, hashToken(normalizeRecoveryCode(code)))
+	if errors.Is(err, store.ErrNotFound) {
+		return errInvalidMFACode
+	}
+
+	return err
+}
+
+// checkTOTP accepts each authenticator code once.
+func (app *application) checkTOTP(ctx context.Context, mfa *store.MFA, code string) error {
+	step, ok := auth.ValidateTOTP(mfa.Secret, code, time.Now())
+	if !ok {
+		return errInvalidMFACode
+	}
+
+	err := app.store.MFA.UseStep(ctx, mfa.UserID, step)
+	if errors.Is(err, store.ErrCodeUsed) {
+		return errInvalidMFACode
+	}
+
+	return err
+}
+
+// newRecoveryCodes returns readable single-use codes and the hashes that are stored.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashedCodes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		b := make([]byte, 10)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashedCodes[i] = hashToken(code)
+	}
+
+	return codes, hashedCodes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
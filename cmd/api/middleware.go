@@ -95,8 +95,40 @@ func (app *application) TokenAuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// Two-factor challenges are signed with the same key but grant nothing
+		if tokenType, _ := claims["typ"].(string); tokenType != "" {
+			app.unAuthorizedError(w, r, fmt.Errorf("not an access token"))
+			return
+		}
+
+		tokenID, _ := claims["jti"].(string)
+		sessionID, _ := claims["sid"].(string)
+		if tokenID == "" || sessionID == "" {
+			app.unAuthorizedError(w, r, fmt.Errorf("missing jti or sid claim"))
+			return
+		}
+
+		expiresAt, err := claims.GetExpirationTime()
+		if err != nil {
+			app.unAuthorizedError(w, r, err)
+			return
+		}
+
 		ctx := r.Context()
 
+		// A token dies with its own revocation or with its session's
+		for _, id := range []string{tokenID, sessionID} {
+			revoked, err := app.isRevoked(ctx, id)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+			if revoked {
+				app.unAuthorizedError(w, r, fmt.Errorf("token revoked"))
+				return
+			}
+		}
+
 		// Check Cache or go db
 		user, err := app.getUser(ctx, userID)
 		if err != nil {
@@ -105,10 +137,29 @@ func (app *application) TokenAuthMiddleware(next http.Handler) http.Handler {
 		}
 
 		ctx = context.WithValue(ctx, userCtx, user)
+		ctx = context.WithValue(ctx, tokenCtx, &accessToken{
+			ID:        tokenID,
+			SessionID: sessionID,
+			ExpiresAt: expiresAt.Time,
+		})
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+func (app *application) RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := getUserFromCtx(r)
+			if user == nil || !user.HasRole(role) {
+				app.forbiddenError(w, r, fmt.Errorf("requires %s role", role))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // for endpoints
 func getUserFromCtx(r *http.Request) *store.User {
 	user, _ := r.Context().Value(userCtx).(*store.User)
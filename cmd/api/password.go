@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/mailer"
+	"github.com/ecetinerdem/forseerv2/internal/store"
+	"github.com/google/uuid"
+)
+
+const (
+	passwordResetMessage = "if the email belongs to an active account, a reset link has been sent"
+	// Reset emails waiting for the mail worker; requests beyond this are dropped
+	passwordResetQueueSize = 100
+)
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+type ResetPasswordPayload struct {
+	Token    string `json:"token" validate:"required,max=255"`
+	Password string `json:"password" validate:"required,min=8,max=16"`
+}
+
+// forgotPasswordHandler godoc
+//
+//	@Summary		Requests a password reset
+//	@Description	Emails a single-use reset link to the account with this email address. The response is the same whether or not an account exists.
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ForgotPasswordPayload	true	"Account email"
+//	@Success		202		{string}	string					"Reset requested"
+//	@Failure		400		{object}	error
+//	@Router			/authentication/password/forgot [post]
+func (app *application) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ForgotPasswordPayload
+
+	err := readJson(w, r, &payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	// Look up and mail in the background so the response time doesn't tell
+	// whether the account exists
+	select {
+	case app.passwordResets <- payload.Email:
+	default:
+		app.logger.Warnw("password reset queue full, request dropped")
+	}
+
+	err = app.writeJsonResponse(w, http.StatusAccepted, passwordResetMessage)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// runPasswordResets mails queued password resets until ctx is cancelled, then
+// sends whatever is still queued so shutdown doesn't drop requests.
+func (app *application) runPasswordResets(ctx context.Context) {
+	app.logger.Info("password reset mailer started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case email := <-app.passwordResets:
+					app.sendPasswordReset(email)
+				default:
+					app.logger.Info("password reset mailer stopped")
+					return
+				}
+			}
+		case email := <-app.passwordResets:
+			app.sendPasswordReset(email)
+		}
+	}
+}
+
+func (app *application) sendPasswordReset(email string) {
+	// A failing mailer must not take the worker down with it
+	defer func() {
+		if p := recover(); p != nil {
+			app.logger.Errorw("password reset panicked", "panic", p)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	user, err := app.store.Users.GetByEmail(ctx, email)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			app.logger.Errorw("error looking up user for password reset", "error", err)
+		}
+		return
+	}
+
+	plainToken := uuid.New().String()
+	err = app.store.Users.CreatePasswordReset(ctx, user.ID, hashToken(plainToken), app.config.auth.resetExpiry)
+	if err != nil {
+		app.logger.Errorw("error creating password reset", "user", user.ID, "error", err)
+		return
+	}
+
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username string
+		ResetURL string
+		Expiry   string
+	}{
+		Username: user.Username,
+		ResetURL: fmt.Sprintf("%s/password/reset/%s", app.config.frontEndURL, plainToken),
+		Expiry:   time.Now().Add(app.config.auth.resetExpiry).Format("2006-01-02 15:04 MST"),
+	}
+
+	status, err := app.mailer.Send(mailer.PasswordResetTemplate, user.Username, user.Email, vars, !isProdEnv)
+	if err != nil {
+		app.logger.Errorw("error sending password reset email", "user", user.ID, "error", err)
+		return
+	}
+
+	app.logger.Infow("Email sent", "status code", status)
+}
+
+// resetPasswordHandler godoc
+//
+//	@Summary		Resets a password
+//	@Description	Sets a new password with a token from a reset email and signs the user out of every session
+//	@Tags			authentication
+//	@Accept			json
+//	@Param			payload	body	ResetPasswordPayload	true	"Reset token and new password"
+//	@Success		204		"Password reset"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/password/reset [post]
+func (app *application) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResetPasswordPayload
+
+	err := readJson(w, r, &payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := &store.User{}
+	err = user.Password.Set(payload.Password)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	sessionIDs, err := app.store.Users.ResetPassword(ctx, payload.Token, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.badRequestError(w, r, errors.New("invalid or expired reset token"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	for _, sessionID := range sessionIDs {
+		err = app.revokeSession(ctx, sessionID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
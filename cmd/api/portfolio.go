@@ -3,9 +3,11 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
+	"github.com/ecetinerdem/forseerv2/internal/marketdata"
 	"github.com/ecetinerdem/forseerv2/internal/store"
 	"github.com/go-chi/chi/v5"
 )
@@ -15,12 +17,18 @@ type portfolioKey string
 const portfolioCtx portfolioKey = "portfolio"
 
 type CreatePortfolioPayload struct {
-	Name   string        `json:"name" validate:"required,max=50"`
-	Stocks []store.Stock `json:"stocks,omitempty"`
+	Name            string        `json:"name" validate:"required,max=50"`
+	CostBasisMethod string        `json:"cost_basis_method" validate:"omitempty,oneof=fifo lifo average"`
+	BaseCurrency    string        `json:"base_currency" validate:"omitempty,iso4217"`
+	BenchmarkSymbol string        `json:"benchmark_symbol" validate:"omitempty,symbol"`
+	Stocks          []store.Stock `json:"stocks,omitempty"`
 }
 
 type UpdatePortfolioPayload struct {
-	Name string `json:"name" validate:"required,max=50"`
+	Name            string  `json:"name" validate:"required,max=50"`
+	CostBasisMethod string  `json:"cost_basis_method" validate:"omitempty,oneof=fifo lifo average"`
+	BaseCurrency    string  `json:"base_currency" validate:"omitempty,iso4217"`
+	BenchmarkSymbol *string `json:"benchmark_symbol" validate:"omitempty,symbol"`
 }
 
 // CreatePost godoc
@@ -61,13 +69,53 @@ func (app *application) createPortfolioHandler(w http.ResponseWriter, r *http.Re
 	}
 
 	portfolio := &store.Portfolio{
-		UserID: user.ID,
-		Name:   createPortfolio.Name,
-		Stocks: createPortfolio.Stocks,
+		UserID:          user.ID,
+		Name:            createPortfolio.Name,
+		CostBasisMethod: createPortfolio.CostBasisMethod,
+		BaseCurrency:    createPortfolio.BaseCurrency,
+		Stocks:          createPortfolio.Stocks,
+	}
+
+	if createPortfolio.BenchmarkSymbol != "" {
+		portfolio.BenchmarkSymbol, err = marketdata.NormalizeSymbol(createPortfolio.BenchmarkSymbol)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
 	}
 
 	ctx := r.Context()
 
+	symbols := make([]string, 0, len(portfolio.Stocks))
+	for i := range portfolio.Stocks {
+		stock := &portfolio.Stocks[i]
+
+		stock.Symbol, err = marketdata.NormalizeSymbol(stock.Symbol)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+		if stock.Currency == "" {
+			stock.Currency, err = app.securityCurrency(ctx, stock.Symbol)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+		}
+		symbols = append(symbols, stock.Symbol)
+	}
+
+	err = app.checkSymbols(ctx, symbols...)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrUnknownSymbol):
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
 	err = app.store.Portfolio.CreatePortfolioWithStocks(ctx, portfolio)
 	if err != nil {
 		app.internalServerError(w, r, err)
@@ -241,7 +289,7 @@ func (app *application) searchPortfoliosHandler(w http.ResponseWriter, r *http.R
 // UpdatePortfolio godoc
 //
 //	@Summary		Updates an existing portfolio
-//	@Description	Updates portfolio fields (currently only the name). Requires version check.
+//	@Description	Updates portfolio name, cost basis method and base currency. Requires version check.
 //	@Tags			portfolios
 //	@Accept			json
 //	@Produce		json
@@ -292,6 +340,26 @@ func (app *application) updatePortfolioHandler(w http.ResponseWriter, r *http.Re
 		portfolio.Name = updatePortfolioPayload.Name
 	}
 
+	if updatePortfolioPayload.CostBasisMethod != "" {
+		portfolio.CostBasisMethod = updatePortfolioPayload.CostBasisMethod
+	}
+
+	if updatePortfolioPayload.BaseCurrency != "" {
+		portfolio.BaseCurrency = updatePortfolioPayload.BaseCurrency
+	}
+
+	// An empty benchmark symbol removes the benchmark
+	if updatePortfolioPayload.BenchmarkSymbol != nil {
+		portfolio.BenchmarkSymbol = ""
+		if *updatePortfolioPayload.BenchmarkSymbol != "" {
+			portfolio.BenchmarkSymbol, err = marketdata.NormalizeSymbol(*updatePortfolioPayload.BenchmarkSymbol)
+			if err != nil {
+				app.badRequestError(w, r, err)
+				return
+			}
+		}
+	}
+
 	updatedPortfolio, err := app.store.Portfolio.UpdatePortfolio(ctx, portfolio, user.ID)
 
 	if err != nil {
@@ -394,12 +462,33 @@ func getPortfolioFromCtx(r *http.Request) *store.Portfolio {
 	return portfolio
 }
 
+// Only members with at least role get through
+func (app *application) requirePortfolioRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			portfolio := getPortfolioFromCtx(r)
+			if portfolio == nil || !store.HasRole(portfolio.Role, role) {
+				app.forbiddenError(w, r, fmt.Errorf("portfolio requires %s role", role))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // Check Cache or go db
 func (app *application) getPortfolio(ctx context.Context, portfolioID int64, userID int64) (*store.Portfolio, error) {
 	if !app.config.redisCfg.enabled {
 		return app.store.Portfolio.GetPortfolioByID(ctx, portfolioID, userID)
 	}
 
+	// The cache is shared by all members, so membership always goes to db
+	role, err := app.store.Members.GetRole(ctx, portfolioID, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	portfolio, err := app.cacheStorage.Portfolio.Get(ctx, portfolioID)
 	if err != nil {
 		return nil, err
@@ -415,6 +504,7 @@ func (app *application) getPortfolio(ctx context.Context, portfolioID int64, use
 			return nil, err
 		}
 	}
+	portfolio.Role = role
 
 	return portfolio, nil
 }
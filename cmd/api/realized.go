@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+// GetRealizedGains godoc
+//
+//	@Summary		Fetches realized gains
+//	@Description	Matches sells against buy lots with the portfolio's cost basis method (fifo, lifo, average) and returns the lots closed in the given year with their gains totalled per currency
+//	@Tags			portfolios
+//	@Produce		json
+//	@Param			portfolioID	path		int	true	"Portfolio ID"
+//	@Param			year		query		int	false	"Tax year, defaults to the current year"
+//	@Success		200			{object}	store.RealizedReport
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error	"Ledger sells more shares than it holds"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/{portfolioID}/realized [get]
+func (app *application) getRealizedGainsHandler(w http.ResponseWriter, r *http.Request) {
+	portfolio := getPortfolioFromCtx(r)
+
+	year := time.Now().Year()
+	if y := r.URL.Query().Get("year"); y != "" {
+		parsed, err := strconv.Atoi(y)
+		if err != nil || parsed < 1900 {
+			app.badRequestError(w, r, errors.New("invalid year"))
+			return
+		}
+		year = parsed
+	}
+
+	ctx := r.Context()
+
+	transactions, err := app.store.Transactions.GetByPortfolio(ctx, portfolio.ID, "")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	report, err := store.RealizedGains(transactions, portfolio.CostBasisMethod, year)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInsufficientShares):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, report)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+)
+
+// SearchSecurities godoc
+//
+//	@Summary		Searches securities
+//	@Description	Autocompletes symbols and names from the securities reference data, best matches first
+//	@Tags			stocks
+//	@Produce		json
+//	@Param			q		query		string	true	"Symbol or name fragment"
+//	@Param			limit	query		int		false	"Maximum results (1-50)"	default(10)
+//	@Success		200		{array}		store.Security
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/securities/search [get]
+func (app *application) searchSecuritiesHandler(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		app.badRequestError(w, r, errors.New("q is required"))
+		return
+	}
+
+	limit := defaultSearchLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed < 1 || parsed > maxSearchLimit {
+			app.badRequestError(w, r, fmt.Errorf("limit must be between 1 and %d", maxSearchLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	ctx := r.Context()
+
+	securities, err := app.store.Securities.Search(ctx, q, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, securities)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// Check the symbols against the securities reference data
+func (app *application) checkSymbols(ctx context.Context, symbols ...string) error {
+	if !app.config.securities.validate || len(symbols) == 0 {
+		return nil
+	}
+
+	unknown, err := app.store.Securities.GetUnknown(ctx, symbols)
+	if err != nil {
+		return err
+	}
+
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: %s", store.ErrUnknownSymbol, strings.Join(unknown, ", "))
+	}
+
+	return nil
+}
+
+// Default a new position's currency to the one its security trades in
+func (app *application) securityCurrency(ctx context.Context, symbol string) (string, error) {
+	security, err := app.store.Securities.GetBySymbol(ctx, symbol)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return security.Currency, nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type tokenKey string
+
+const tokenCtx tokenKey = "token"
+
+// accessToken identifies the bearer token of the request
+type accessToken struct {
+	ID        string
+	SessionID string
+	ExpiresAt time.Time
+}
+
+func getTokenFromCtx(r *http.Request) *accessToken {
+	token, _ := r.Context().Value(tokenCtx).(*accessToken)
+
+	return token
+}
+
+// Logout godoc
+//
+//	@Summary		Logs out
+//	@Description	Revokes the access token of the request and ends its session, so the session's refresh token stops working too
+//	@Tags			authentication
+//	@Success		204	"Logged out"
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/logout [post]
+func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	token := getTokenFromCtx(r)
+
+	ctx := r.Context()
+
+	err := app.store.Sessions.Revoke(ctx, token.SessionID, user.ID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.revoke(ctx, token.ID, token.ExpiresAt)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.revokeSession(ctx, token.SessionID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll godoc
+//
+//	@Summary		Logs out everywhere
+//	@Description	Ends every session of the user, including the current one, and revokes their access and refresh tokens
+//	@Tags			authentication
+//	@Success		204	"Logged out of all sessions"
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/logout-all [post]
+func (app *application) logoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	token := getTokenFromCtx(r)
+
+	ctx := r.Context()
+
+	sessionIDs, err := app.store.Sessions.RevokeAll(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.revoke(ctx, token.ID, token.ExpiresAt)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for _, sessionID := range sessionIDs {
+		err = app.revokeSession(ctx, sessionID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSessions godoc
+//
+//	@Summary		Lists active sessions
+//	@Description	Lists the devices the user is signed in on, most recently used first. current marks the session of the request.
+//	@Tags			authentication
+//	@Produce		json
+//	@Success		200	{array}		store.Session
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/sessions [get]
+func (app *application) getSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	token := getTokenFromCtx(r)
+
+	ctx := r.Context()
+
+	sessions, err := app.store.Sessions.GetByUser(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == token.SessionID
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, sessions)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// RevokeSession godoc
+//
+//	@Summary		Ends a session
+//	@Description	Signs a device out by revoking its session and the tokens issued to it
+//	@Tags			authentication
+//	@Param			sessionID	path	string	true	"Session ID"
+//	@Success		204			"Session revoked"
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/sessions/{sessionID} [delete]
+func (app *application) revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	sessionID, err := uuid.Parse(chi.URLParam(r, "sessionID"))
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	err = app.store.Sessions.Revoke(ctx, sessionID.String(), user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.revokeSession(ctx, sessionID.String())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Access tokens of a session live at most one token expiry past its end
+func (app *application) revokeSession(ctx context.Context, sessionID string) error {
+	return app.revoke(ctx, sessionID, time.Now().Add(app.config.auth.token.expiry))
+}
+
+// Check Cache or go db
+func (app *application) revoke(ctx context.Context, id string, expiry time.Time) error {
+	if !app.config.redisCfg.enabled {
+		return app.store.Revocations.Revoke(ctx, id, expiry)
+	}
+
+	return app.cacheStorage.Revocations.Revoke(ctx, id, expiry)
+}
+
+// Check Cache or go db
+func (app *application) isRevoked(ctx context.Context, id string) (bool, error) {
+	if !app.config.redisCfg.enabled {
+		return app.store.Revocations.IsRevoked(ctx, id)
+	}
+
+	return app.cacheStorage.Revocations.IsRevoked(ctx, id)
+}
+
+// clientIP is the request's address without the port. RealIP has already
+// replaced RemoteAddr with the forwarded client address when there is one.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
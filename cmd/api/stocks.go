@@ -1,23 +1,33 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/ecetinerdem/forseerv2/internal/marketdata"
 	"github.com/ecetinerdem/forseerv2/internal/store"
 	"github.com/go-chi/chi/v5"
 )
 
+// quoteConcurrency caps the provider requests made at once for one response
+const quoteConcurrency = 8
+
 type AddStockPayload struct {
-	Symbol       string  `json:"symbol" validate:"required,min=1,max=4"`
+	Symbol       string  `json:"symbol" validate:"required,symbol"`
 	Shares       float64 `json:"shares" validate:"required,gt=0"`
-	AveragePrice float64 `json:"average_price" validate:"requiredgt=0"`
+	AveragePrice float64 `json:"average_price" validate:"required,gt=0"`
+	Currency     string  `json:"currency" validate:"omitempty,iso4217"`
 }
 
 type UpdateStockPayload struct {
-	Symbol       string  `json:"symbol" validate:"required,min=1,max=4"`
+	Symbol       string  `json:"symbol" validate:"required,symbol"`
 	Shares       float64 `json:"shares" validate:"required,gt=0"`
-	AveragePrice float64 `json:"average_price" validate:"requiredgt=0"`
+	AveragePrice float64 `json:"average_price" validate:"required,gt=0"`
+	Currency     string  `json:"currency" validate:"omitempty,iso4217"`
 }
 
 // AddStockToPortfolio godoc
@@ -39,9 +49,7 @@ type UpdateStockPayload struct {
 //	@Router			/portfolios/{portfolioID}/stocks [post]
 func (app *application) addStockHandler(w http.ResponseWriter, r *http.Request) {
 	portfolio := getPortfolioFromCtx(r)
-
-	//Get from the auth later
-	UserID := 1
+	user := getUserFromCtx(r)
 
 	ctx := r.Context()
 
@@ -59,17 +67,40 @@ func (app *application) addStockHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	stock := &store.Stock{
-		Symbol:       addStockPayload.Symbol,
+		Symbol:       strings.ToUpper(strings.TrimSpace(addStockPayload.Symbol)),
 		Shares:       addStockPayload.Shares,
 		AveragePrice: addStockPayload.AveragePrice,
+		Currency:     addStockPayload.Currency,
+	}
+
+	err = app.checkSymbols(ctx, stock.Symbol)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrUnknownSymbol):
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if stock.Currency == "" {
+		stock.Currency, err = app.securityCurrency(ctx, stock.Symbol)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
 	}
-	err = app.store.Portfolio.AddStockToPortfolio(ctx, portfolio.ID, int64(UserID), stock)
+
+	err = app.store.Portfolio.AddStockToPortfolio(ctx, portfolio.ID, user.ID, stock)
 	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
 			app.notFoundError(w, r, err)
 		case errors.Is(err, store.ErrDuplicateStock):
 			app.duplicateError(w, r, err)
+		case errors.Is(err, store.ErrCurrencyMismatch):
+			app.badRequestError(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
 		}
@@ -86,7 +117,7 @@ func (app *application) addStockHandler(w http.ResponseWriter, r *http.Request)
 // UpdateStockInPortfolio godoc
 //
 //	@Summary		Update a stock in a portfolio
-//	@Description	Updates shares and average price of an existing stock
+//	@Description	Records the change in shares as a buy or sell at the given price and returns the position derived from the ledger
 //	@Tags			portfolios
 //	@Accept			json
 //	@Produce		json
@@ -103,9 +134,7 @@ func (app *application) addStockHandler(w http.ResponseWriter, r *http.Request)
 func (app *application) updateStockHandler(w http.ResponseWriter, r *http.Request) {
 
 	portfolio := getPortfolioFromCtx(r)
-
-	//Get from the auth later
-	UserID := 1
+	user := getUserFromCtx(r)
 
 	ctx := r.Context()
 
@@ -123,16 +152,31 @@ func (app *application) updateStockHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	stock := &store.Stock{
-		Symbol:       addStockPayload.Symbol,
+		Symbol:       strings.ToUpper(strings.TrimSpace(addStockPayload.Symbol)),
 		Shares:       addStockPayload.Shares,
 		AveragePrice: addStockPayload.AveragePrice,
+		Currency:     addStockPayload.Currency,
+	}
+
+	err = app.checkSymbols(ctx, stock.Symbol)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrUnknownSymbol):
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
 	}
-	updatedStock, err := app.store.Portfolio.UpdateStockToPortfolio(ctx, portfolio.ID, int64(UserID), stock)
+
+	updatedStock, err := app.store.Portfolio.UpdateStockToPortfolio(ctx, portfolio.ID, user.ID, stock)
 
 	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
 			app.notFoundError(w, r, err)
+		case errors.Is(err, store.ErrInsufficientShares), errors.Is(err, store.ErrCurrencyMismatch):
+			app.badRequestError(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
 		}
@@ -164,14 +208,12 @@ func (app *application) updateStockHandler(w http.ResponseWriter, r *http.Reques
 //	@Router			/portfolios/{portfolioID}/stocks/{symbol} [delete]
 func (app *application) deleteStockHandler(w http.ResponseWriter, r *http.Request) {
 	portfolio := getPortfolioFromCtx(r)
+	user := getUserFromCtx(r)
 	symbol := chi.URLParam(r, "symbol")
 
-	//Get from the auth later
-	UserID := 1
-
 	ctx := r.Context()
 
-	err := app.store.Portfolio.DeleteStockFromPortfolio(ctx, portfolio.ID, int64(UserID), symbol)
+	err := app.store.Portfolio.DeleteStockFromPortfolio(ctx, portfolio.ID, user.ID, symbol)
 
 	if err != nil {
 		switch {
@@ -184,3 +226,258 @@ func (app *application) deleteStockHandler(w http.ResponseWriter, r *http.Reques
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// GetStockQuote godoc
+//
+//	@Summary		Fetches a stock quote
+//	@Description	Fetches the latest price of a symbol from the configured market data provider
+//	@Tags			stocks
+//	@Produce		json
+//	@Param			symbol	path		string	true	"Stock Symbol"
+//	@Success		200		{object}	marketdata.Quote
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/stocks/{symbol}/quote [get]
+func (app *application) getStockQuoteHandler(w http.ResponseWriter, r *http.Request) {
+	symbol := chi.URLParam(r, "symbol")
+
+	ctx := r.Context()
+
+	quote, err := app.marketData.Quote(ctx, symbol)
+	if err != nil {
+		switch {
+		case errors.Is(err, marketdata.ErrInvalidSymbol):
+			app.badRequestError(w, r, err)
+		case errors.Is(err, marketdata.ErrSymbolNotFound), errors.Is(err, marketdata.ErrNoData):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, quote)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetStockHistory godoc
+//
+//	@Summary		Fetches daily price history
+//	@Description	Fetches daily OHLCV bars for a symbol between from and to (YYYY-MM-DD), defaulting to the last year
+//	@Tags			stocks
+//	@Produce		json
+//	@Param			symbol	path		string	true	"Stock Symbol"
+//	@Param			from	query		string	false	"Start date (YYYY-MM-DD)"
+//	@Param			to		query		string	false	"End date (YYYY-MM-DD)"
+//	@Success		200		{object}	store.StockHistory
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/stocks/{symbol}/history [get]
+func (app *application) getStockHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	symbol, err := marketdata.NormalizeSymbol(chi.URLParam(r, "symbol"))
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	drq := &store.DateRangeQuery{
+		From: today.AddDate(-1, 0, 0),
+		To:   today,
+	}
+
+	drq, err = drq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(drq)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	history, err := app.getStockHistory(ctx, symbol, drq.From, drq.To)
+	if err != nil {
+		switch {
+		case errors.Is(err, marketdata.ErrSymbolNotFound), errors.Is(err, marketdata.ErrNoData):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, history)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// Check db or go market data provider for the dates the db is missing
+func (app *application) getStockHistory(ctx context.Context, symbol string, from, to time.Time) (*store.StockHistory, error) {
+	history, err := app.store.Stocks.GetHistory(ctx, symbol, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(history.Data) == 0 {
+		history, err = app.marketData.History(ctx, symbol, from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		err = app.store.Stocks.UpsertHistory(ctx, history)
+		if err != nil {
+			return nil, err
+		}
+
+		return history, nil
+	}
+
+	first := history.Data[0].Date
+	last := history.Data[len(history.Data)-1].Date
+
+	gaps := [][2]time.Time{
+		{from, first.AddDate(0, 0, -1)},
+		{last.AddDate(0, 0, 1), to},
+	}
+
+	fetched := false
+	for _, gap := range gaps {
+		if !hasWeekday(gap[0], gap[1]) {
+			continue
+		}
+
+		missing, err := app.marketData.History(ctx, symbol, gap[0], gap[1])
+		if err != nil {
+			// What is stored is still worth serving
+			if !errors.Is(err, marketdata.ErrNoData) {
+				app.logger.Warnw("market data history failed, serving stored range", "symbol", symbol, "error", err)
+			}
+			continue
+		}
+
+		err = app.store.Stocks.UpsertHistory(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		fetched = true
+	}
+
+	if !fetched {
+		return history, nil
+	}
+
+	return app.store.Stocks.GetHistory(ctx, symbol, from, to)
+}
+
+// hasWeekday reports whether a trading day may fall between from and to inclusive.
+func hasWeekday(from, to time.Time) bool {
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() != time.Saturday && d.Weekday() != time.Sunday {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Ask market data provider or fall back to the last stored close
+func (app *application) getLatestQuote(ctx context.Context, symbol string) (*marketdata.Quote, error) {
+	quote, err := app.marketData.Quote(ctx, symbol)
+	if err == nil {
+		return quote, nil
+	}
+
+	app.logger.Warnw("market data quote failed, using stored close", "symbol", symbol, "error", err)
+
+	latest, err := app.store.Stocks.GetLatestPrice(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	return &marketdata.Quote{
+		Symbol: symbol,
+		Price:  latest.Close,
+		Date:   latest.Date,
+	}, nil
+}
+
+// getLatestQuotes quotes the distinct symbols concurrently, leaving out
+// those without any price.
+func (app *application) getLatestQuotes(ctx context.Context, symbols []string) (map[string]*marketdata.Quote, error) {
+	quotes := make(map[string]*marketdata.Quote, len(symbols))
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	sem := make(chan struct{}, quoteConcurrency)
+
+	seen := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		if seen[symbol] {
+			continue
+		}
+		seen[symbol] = true
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(symbol string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			quote, err := app.getLatestQuote(ctx, symbol)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				quotes[symbol] = quote
+			case !errors.Is(err, store.ErrNotFound) && firstErr == nil:
+				firstErr = err
+			}
+		}(symbol)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return quotes, nil
+}
+
+func (app *application) getLatestPrices(ctx context.Context, stocks []store.Stock) (map[string]float64, error) {
+	symbols := make([]string, 0, len(stocks))
+	for _, stock := range stocks {
+		symbols = append(symbols, stock.Symbol)
+	}
+
+	quotes, err := app.getLatestQuotes(ctx, symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make(map[string]float64, len(quotes))
+	for symbol, quote := range quotes {
+		prices[symbol] = quote.Price
+	}
+
+	return prices, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/analysis"
+)
+
+// GetPortfoliosSummary godoc
+//
+//	@Summary		Fetches a combined summary of all portfolios
+//	@Description	Combines all of the portfolios the user owns into one holdings list, leaving out portfolios shared with them, with total value, cash, unrealized P&L, per-portfolio totals and allocation weights. Values are in the given currency, defaulting to the portfolios' shared base currency or USD.
+//	@Tags			portfolios
+//	@Produce		json
+//	@Param			currency	query		string	false	"Reporting currency (ISO 4217)"
+//	@Success		200			{object}	analysis.Summary
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/summary [get]
+func (app *application) getPortfoliosSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	currency := strings.ToUpper(r.URL.Query().Get("currency"))
+	if currency != "" {
+		err := Validate.Var(currency, "iso4217")
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	portfolios, err := app.getUserPortfolios(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if currency == "" {
+		currency = commonBaseCurrency(portfolios)
+	}
+
+	valuations := make([]*analysis.Valuation, 0, len(portfolios))
+	bases := make([]string, 0, len(portfolios))
+	for _, portfolio := range portfolios {
+		valuation, err := app.valuePortfolio(ctx, portfolio)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		valuations = append(valuations, valuation)
+		bases = append(bases, portfolio.BaseCurrency)
+	}
+
+	rates, err := app.getFXRates(ctx, bases, currency, time.Now())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	summary := analysis.Summarize(portfolios, valuations, currency, rates)
+
+	err = app.writeJsonResponse(w, http.StatusOK, summary)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
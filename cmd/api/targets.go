@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/ecetinerdem/forseerv2/internal/analysis"
+	"github.com/ecetinerdem/forseerv2/internal/marketdata"
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+type TargetPayload struct {
+	Symbol string  `json:"symbol" validate:"required,symbol"`
+	Weight float64 `json:"weight" validate:"gt=0,lte=1"`
+}
+
+type SetTargetsPayload struct {
+	Targets          []TargetPayload `json:"targets" validate:"dive"`
+	FractionalShares bool            `json:"fractional_shares"`
+	DriftTolerance   *float64        `json:"drift_tolerance" validate:"omitempty,gte=0,lte=1"`
+}
+
+type RebalancePayload struct {
+	NewCash float64 `json:"new_cash" validate:"gte=0"`
+}
+
+// GetTargets godoc
+//
+//	@Summary		Fetches target allocation
+//	@Description	Returns the portfolio's target weights (fractions of 1) and rebalancing settings
+//	@Tags			portfolios
+//	@Produce		json
+//	@Param			portfolioID	path		int	true	"Portfolio ID"
+//	@Success		200			{object}	store.TargetAllocation
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/{portfolioID}/targets [get]
+func (app *application) getTargetsHandler(w http.ResponseWriter, r *http.Request) {
+	portfolio := getPortfolioFromCtx(r)
+
+	ctx := r.Context()
+
+	allocation, err := app.store.Targets.Get(ctx, portfolio.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, allocation)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// SetTargets godoc
+//
+//	@Summary		Sets target allocation
+//	@Description	Replaces the portfolio's target weights, which must not add up to more than 1 (the rest is held as cash), along with whole vs fractional share trading and the drift tolerance
+//	@Tags			portfolios
+//	@Accept			json
+//	@Produce		json
+//	@Param			portfolioID	path		int					true	"Portfolio ID"
+//	@Param			payload		body		SetTargetsPayload	true	"Targets payload"
+//	@Success		200			{object}	store.TargetAllocation
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/{portfolioID}/targets [put]
+func (app *application) setTargetsHandler(w http.ResponseWriter, r *http.Request) {
+	portfolio := getPortfolioFromCtx(r)
+	user := getUserFromCtx(r)
+
+	var payload SetTargetsPayload
+	err := readJson(w, r, &payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	allocation, err := app.store.Targets.Get(ctx, portfolio.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	allocation.FractionalShares = payload.FractionalShares
+	if payload.DriftTolerance != nil {
+		allocation.DriftTolerance = *payload.DriftTolerance
+	}
+
+	allocation.Targets = make([]store.Target, 0, len(payload.Targets))
+	seen := map[string]bool{}
+	var sum float64
+
+	for _, t := range payload.Targets {
+		symbol, err := marketdata.NormalizeSymbol(t.Symbol)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+		if seen[symbol] {
+			app.badRequestError(w, r, fmt.Errorf("duplicate target for %s", symbol))
+			return
+		}
+		seen[symbol] = true
+		sum += t.Weight
+
+		allocation.Targets = append(allocation.Targets, store.Target{Symbol: symbol, Weight: t.Weight})
+	}
+
+	if sum > 1+1e-9 {
+		app.badRequestError(w, r, fmt.Errorf("target weights add up to %g, more than 1", sum))
+		return
+	}
+
+	err = app.store.Targets.Set(ctx, user.ID, allocation)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, allocation)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// RebalancePortfolio godoc
+//
+//	@Summary		Suggests rebalancing orders
+//	@Description	Given latest prices, the cash already held and optional new cash (in the base currency), returns the buy and sell orders that bring holdings back to their target weights once any of them drifts beyond the tolerance. Nothing is traded or stored, so any member may ask, viewers included.
+//	@Tags			portfolios
+//	@Accept			json
+//	@Produce		json
+//	@Param			portfolioID	path		int					true	"Portfolio ID"
+//	@Param			payload		body		RebalancePayload	false	"Rebalance payload"
+//	@Success		200			{object}	analysis.RebalancePlan
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/{portfolioID}/rebalance [post]
+func (app *application) rebalancePortfolioHandler(w http.ResponseWriter, r *http.Request) {
+	portfolio := getPortfolioFromCtx(r)
+
+	// The body is optional
+	var payload RebalancePayload
+	err := readJson(w, r, &payload)
+	if err != nil && !errors.Is(err, io.EOF) {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	allocation, err := app.store.Targets.Get(ctx, portfolio.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if len(allocation.Targets) == 0 {
+		app.badRequestError(w, r, errors.New("portfolio has no target allocation"))
+		return
+	}
+
+	valuation, err := app.valuePortfolio(ctx, portfolio)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	held := map[string]bool{}
+	for _, stock := range portfolio.Stocks {
+		held[stock.Symbol] = true
+	}
+
+	missing := []store.Stock{}
+	for _, t := range allocation.Targets {
+		if !held[t.Symbol] {
+			missing = append(missing, store.Stock{Symbol: t.Symbol})
+		}
+	}
+
+	prices, err := app.getLatestPrices(ctx, missing)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	plan := analysis.Rebalance(valuation, allocation, prices, payload.NewCash)
+
+	err = app.writeJsonResponse(w, http.StatusOK, plan)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
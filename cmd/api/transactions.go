@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+type CreateTransactionPayload struct {
+	Symbol    string  `json:"symbol" validate:"required,symbol"`
+	Type      string  `json:"type" validate:"required,oneof=buy sell split dividend"`
+	TradeDate string  `json:"trade_date" validate:"required,datetime=2006-01-02"`
+	Quantity  float64 `json:"quantity" validate:"required,gt=0"`
+	Price     float64 `json:"price" validate:"gte=0"`
+	Fees      float64 `json:"fees" validate:"gte=0"`
+	Currency  string  `json:"currency" validate:"omitempty,iso4217"`
+}
+
+type TransactionWithPosition struct {
+	Transaction *store.Transaction `json:"transaction"`
+	Position    *store.Stock       `json:"position"`
+}
+
+// CreateTransaction godoc
+//
+//	@Summary		Records a portfolio transaction
+//	@Description	Appends a buy, sell, split or dividend to the ledger and returns the rebuilt position (null when closed). A dividend also credits quantity x price less fees to cash. For splits quantity is the ratio, e.g. 2 for a 2-for-1 split.
+//	@Tags			portfolios
+//	@Accept			json
+//	@Produce		json
+//	@Param			portfolioID	path		int							true	"Portfolio ID"
+//	@Param			payload		body		CreateTransactionPayload	true	"Transaction payload"
+//	@Success		201			{object}	TransactionWithPosition
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/{portfolioID}/transactions [post]
+func (app *application) createTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	portfolio := getPortfolioFromCtx(r)
+	user := getUserFromCtx(r)
+
+	var payload CreateTransactionPayload
+	err := readJson(w, r, &payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	tradeDate, err := time.Parse(store.DateLayout, payload.TradeDate)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	transaction := &store.Transaction{
+		Symbol:    strings.ToUpper(strings.TrimSpace(payload.Symbol)),
+		Type:      payload.Type,
+		TradeDate: tradeDate,
+		Quantity:  payload.Quantity,
+		Price:     payload.Price,
+		Fees:      payload.Fees,
+		Currency:  payload.Currency,
+	}
+
+	ctx := r.Context()
+
+	err = app.checkSymbols(ctx, transaction.Symbol)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrUnknownSymbol):
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	position, err := app.store.Transactions.Record(ctx, portfolio.ID, user.ID, transaction)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		case errors.Is(err, store.ErrInsufficientShares), errors.Is(err, store.ErrCurrencyMismatch):
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusCreated, TransactionWithPosition{
+		Transaction: transaction,
+		Position:    position,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetTransactions godoc
+//
+//	@Summary		Lists portfolio transactions
+//	@Description	Lists the ledger of a portfolio in trade date order, optionally filtered by symbol
+//	@Tags			portfolios
+//	@Produce		json
+//	@Param			portfolioID	path		int		true	"Portfolio ID"
+//	@Param			symbol		query		string	false	"Stock Symbol"
+//	@Success		200			{array}		store.Transaction
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/{portfolioID}/transactions [get]
+func (app *application) getTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	portfolio := getPortfolioFromCtx(r)
+	symbol := strings.ToUpper(r.URL.Query().Get("symbol"))
+
+	ctx := r.Context()
+
+	transactions, err := app.store.Transactions.GetByPortfolio(ctx, portfolio.ID, symbol)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, transactions)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
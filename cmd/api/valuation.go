@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/analysis"
+	"github.com/ecetinerdem/forseerv2/internal/fx"
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+// GetPortfolioValuation godoc
+//
+//	@Summary		Values a portfolio
+//	@Description	Combines positions and cash with latest prices to return market value, cost basis, unrealized P&L and weights converted to the base currency, plus native totals per currency
+//	@Tags			portfolios
+//	@Produce		json
+//	@Param			portfolioID	path		int	true	"Portfolio ID"
+//	@Success		200			{object}	analysis.Valuation
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/portfolios/{portfolioID}/valuation [get]
+func (app *application) getPortfolioValuationHandler(w http.ResponseWriter, r *http.Request) {
+	portfolio := getPortfolioFromCtx(r)
+
+	valuation, err := app.valuePortfolio(r.Context(), portfolio)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, valuation)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// Value the portfolio at the latest prices and today's exchange rates
+func (app *application) valuePortfolio(ctx context.Context, portfolio *store.Portfolio) (*analysis.Valuation, error) {
+	prices, err := app.getLatestPrices(ctx, portfolio.Stocks)
+	if err != nil {
+		return nil, err
+	}
+
+	cash, err := app.store.Cash.GetBalances(ctx, portfolio.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	currencies := make([]string, 0, len(portfolio.Stocks)+len(cash))
+	for _, stock := range portfolio.Stocks {
+		currencies = append(currencies, stock.Currency)
+	}
+	for _, balance := range cash {
+		currencies = append(currencies, balance.Currency)
+	}
+
+	rates, err := app.getFXRates(ctx, currencies, portfolio.BaseCurrency, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return analysis.Value(portfolio, prices, cash, rates), nil
+}
+
+// Look up the rate into base for each currency, skipping those without one
+func (app *application) getFXRates(ctx context.Context, currencies []string, base string, date time.Time) (map[string]float64, error) {
+	rates := map[string]float64{}
+
+	for _, currency := range currencies {
+		if _, ok := rates[currency]; ok || currency == base {
+			continue
+		}
+
+		rate, err := app.fx.Rate(ctx, currency, base, date)
+		if err != nil {
+			if errors.Is(err, fx.ErrRateNotFound) {
+				app.logger.Warnw("no fx rate", "from", currency, "to", base)
+				continue
+			}
+			return nil, err
+		}
+
+		rates[currency] = rate
+	}
+
+	return rates, nil
+}
+
+// Like getFXRates, but quiet about missing rates since it runs for every day
+// of a period
+func (app *application) getHistoricalFXRates(ctx context.Context, currencies []string, base string, date time.Time) (map[string]float64, error) {
+	rates := map[string]float64{}
+	seen := map[string]bool{}
+
+	for _, currency := range currencies {
+		if seen[currency] || currency == base {
+			continue
+		}
+		seen[currency] = true
+
+		rate, err := app.fx.Rate(ctx, currency, base, date)
+		if err != nil {
+			if errors.Is(err, fx.ErrRateNotFound) {
+				continue
+			}
+			return nil, err
+		}
+
+		rates[currency] = rate
+	}
+
+	return rates, nil
+}
@@ -0,0 +1,548 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/marketdata"
+	"github.com/ecetinerdem/forseerv2/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type watchlistKey string
+
+const watchlistCtx watchlistKey = "watchlist"
+
+type WatchlistPayload struct {
+	Name string `json:"name" validate:"required,max=50"`
+}
+
+type AddWatchlistItemPayload struct {
+	Symbol string `json:"symbol" validate:"required,symbol"`
+	Note   string `json:"note" validate:"max=255"`
+}
+
+type UpdateWatchlistItemPayload struct {
+	Note string `json:"note" validate:"max=255"`
+}
+
+type ReorderWatchlistPayload struct {
+	Symbols []string `json:"symbols" validate:"required,dive,symbol"`
+}
+
+type WatchlistQuote struct {
+	Price         float64   `json:"price"`
+	PreviousClose float64   `json:"previous_close"`
+	Change        float64   `json:"change"`
+	ChangePercent float64   `json:"change_percent"`
+	Date          time.Time `json:"date"`
+}
+
+type WatchlistItemWithQuote struct {
+	store.WatchlistItem
+	Quote *WatchlistQuote `json:"quote"`
+}
+
+type WatchlistWithQuotes struct {
+	*store.Watchlist
+	Items []WatchlistItemWithQuote `json:"items"`
+}
+
+// CreateWatchlist godoc
+//
+//	@Summary		Creates a watchlist
+//	@Description	Creates an empty watchlist for following symbols without holding them
+//	@Tags			watchlists
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		WatchlistPayload	true	"Watchlist payload"
+//	@Success		201		{object}	store.Watchlist
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/watchlists [post]
+func (app *application) createWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	var payload WatchlistPayload
+	err := readJson(w, r, &payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	watchlist := &store.Watchlist{
+		UserID: user.ID,
+		Name:   payload.Name,
+	}
+
+	ctx := r.Context()
+
+	err = app.store.Watchlists.Create(ctx, watchlist)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrDuplicateWatchlist):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusCreated, watchlist)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetWatchlists godoc
+//
+//	@Summary		Lists watchlists
+//	@Description	Lists the authenticated user's watchlists with their item counts
+//	@Tags			watchlists
+//	@Produce		json
+//	@Success		200	{array}		store.Watchlist
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/watchlists [get]
+func (app *application) getWatchlistsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	ctx := r.Context()
+
+	watchlists, err := app.store.Watchlists.GetByUser(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, watchlists)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetWatchlist godoc
+//
+//	@Summary		Fetches a watchlist
+//	@Description	Fetches a watchlist with its items in order and the latest quote for each symbol (null when unavailable)
+//	@Tags			watchlists
+//	@Produce		json
+//	@Param			watchlistID	path		int	true	"Watchlist ID"
+//	@Success		200			{object}	WatchlistWithQuotes
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/watchlists/{watchlistID} [get]
+func (app *application) getWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	watchlist := getWatchlistFromCtx(r)
+
+	ctx := r.Context()
+
+	response := WatchlistWithQuotes{
+		Watchlist: watchlist,
+		Items:     make([]WatchlistItemWithQuote, 0, len(watchlist.Items)),
+	}
+
+	symbols := make([]string, 0, len(watchlist.Items))
+	for _, item := range watchlist.Items {
+		symbols = append(symbols, item.Symbol)
+	}
+
+	quotes, err := app.getLatestQuotes(ctx, symbols)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for _, item := range watchlist.Items {
+		response.Items = append(response.Items, WatchlistItemWithQuote{
+			WatchlistItem: item,
+			Quote:         newWatchlistQuote(quotes[item.Symbol]),
+		})
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, response)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// UpdateWatchlist godoc
+//
+//	@Summary		Renames a watchlist
+//	@Description	Renames a watchlist by its ID
+//	@Tags			watchlists
+//	@Accept			json
+//	@Produce		json
+//	@Param			watchlistID	path		int					true	"Watchlist ID"
+//	@Param			payload		body		WatchlistPayload	true	"Watchlist payload"
+//	@Success		200			{object}	store.Watchlist
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/watchlists/{watchlistID} [patch]
+func (app *application) updateWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	watchlist := getWatchlistFromCtx(r)
+
+	var payload WatchlistPayload
+	err := readJson(w, r, &payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	watchlist.Name = payload.Name
+
+	ctx := r.Context()
+
+	err = app.store.Watchlists.Update(ctx, watchlist)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		case errors.Is(err, store.ErrDuplicateWatchlist):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, watchlist)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// DeleteWatchlist godoc
+//
+//	@Summary		Deletes a watchlist
+//	@Description	Removes a watchlist and its items permanently
+//	@Tags			watchlists
+//	@Param			watchlistID	path	int	true	"Watchlist ID"
+//	@Success		204			"Watchlist deleted successfully"
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/watchlists/{watchlistID} [delete]
+func (app *application) deleteWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	watchlist := getWatchlistFromCtx(r)
+
+	ctx := r.Context()
+
+	err := app.store.Watchlists.Delete(ctx, watchlist.ID, watchlist.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddWatchlistItem godoc
+//
+//	@Summary		Adds a symbol to a watchlist
+//	@Description	Appends a symbol with an optional note to the end of the watchlist
+//	@Tags			watchlists
+//	@Accept			json
+//	@Produce		json
+//	@Param			watchlistID	path		int						true	"Watchlist ID"
+//	@Param			payload		body		AddWatchlistItemPayload	true	"Item payload"
+//	@Success		201			{object}	store.WatchlistItem
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/watchlists/{watchlistID}/items [post]
+func (app *application) addWatchlistItemHandler(w http.ResponseWriter, r *http.Request) {
+	watchlist := getWatchlistFromCtx(r)
+
+	var payload AddWatchlistItemPayload
+	err := readJson(w, r, &payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	item := &store.WatchlistItem{
+		WatchlistID: watchlist.ID,
+		Symbol:      strings.ToUpper(strings.TrimSpace(payload.Symbol)),
+		Note:        payload.Note,
+	}
+
+	ctx := r.Context()
+
+	err = app.checkSymbols(ctx, item.Symbol)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrUnknownSymbol):
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.store.Watchlists.AddItem(ctx, item)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrDuplicateSymbol):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusCreated, item)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// UpdateWatchlistItem godoc
+//
+//	@Summary		Updates a watchlist note
+//	@Description	Replaces the note on a symbol in the watchlist
+//	@Tags			watchlists
+//	@Accept			json
+//	@Produce		json
+//	@Param			watchlistID	path		int							true	"Watchlist ID"
+//	@Param			symbol		path		string						true	"Stock Symbol"
+//	@Param			payload		body		UpdateWatchlistItemPayload	true	"Item payload"
+//	@Success		200			{object}	store.WatchlistItem
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/watchlists/{watchlistID}/items/{symbol} [patch]
+func (app *application) updateWatchlistItemHandler(w http.ResponseWriter, r *http.Request) {
+	watchlist := getWatchlistFromCtx(r)
+
+	var payload UpdateWatchlistItemPayload
+	err := readJson(w, r, &payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	item := &store.WatchlistItem{
+		WatchlistID: watchlist.ID,
+		Symbol:      strings.ToUpper(chi.URLParam(r, "symbol")),
+		Note:        payload.Note,
+	}
+
+	ctx := r.Context()
+
+	err = app.store.Watchlists.UpdateItem(ctx, item)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, item)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// RemoveWatchlistItem godoc
+//
+//	@Summary		Removes a symbol from a watchlist
+//	@Description	Removes a symbol from the watchlist
+//	@Tags			watchlists
+//	@Param			watchlistID	path	int		true	"Watchlist ID"
+//	@Param			symbol		path	string	true	"Stock Symbol"
+//	@Success		204			"Symbol removed successfully"
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/watchlists/{watchlistID}/items/{symbol} [delete]
+func (app *application) removeWatchlistItemHandler(w http.ResponseWriter, r *http.Request) {
+	watchlist := getWatchlistFromCtx(r)
+	symbol := strings.ToUpper(chi.URLParam(r, "symbol"))
+
+	ctx := r.Context()
+
+	err := app.store.Watchlists.RemoveItem(ctx, watchlist.ID, symbol)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReorderWatchlist godoc
+//
+//	@Summary		Reorders a watchlist
+//	@Description	Sets the display order of the watchlist; symbols must list every item exactly once
+//	@Tags			watchlists
+//	@Accept			json
+//	@Param			watchlistID	path	int						true	"Watchlist ID"
+//	@Param			payload		body	ReorderWatchlistPayload	true	"Order payload"
+//	@Success		204			"Watchlist reordered successfully"
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/watchlists/{watchlistID}/items/order [put]
+func (app *application) reorderWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	watchlist := getWatchlistFromCtx(r)
+
+	var payload ReorderWatchlistPayload
+	err := readJson(w, r, &payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(&payload)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	symbols := make([]string, 0, len(payload.Symbols))
+	for _, symbol := range payload.Symbols {
+		symbols = append(symbols, strings.ToUpper(strings.TrimSpace(symbol)))
+	}
+
+	ctx := r.Context()
+
+	err = app.store.Watchlists.Reorder(ctx, watchlist.ID, symbols)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidOrder):
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) watchlistsContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := getUserFromCtx(r)
+		if user == nil {
+			app.unAuthorizedError(w, r, errors.New("unauthorized"))
+			return
+		}
+
+		watchlistID, err := strconv.ParseInt(chi.URLParam(r, "watchlistID"), 10, 64)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+
+		watchlist, err := app.store.Watchlists.GetByID(ctx, watchlistID, user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, watchlistCtx, watchlist)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getWatchlistFromCtx(r *http.Request) *store.Watchlist {
+	watchlist, _ := r.Context().Value(watchlistCtx).(*store.Watchlist)
+
+	return watchlist
+}
+
+// newWatchlistQuote adds the day's change when the quote has a previous close
+func newWatchlistQuote(quote *marketdata.Quote) *WatchlistQuote {
+	if quote == nil {
+		return nil
+	}
+
+	q := &WatchlistQuote{
+		Price:         quote.Price,
+		PreviousClose: quote.PreviousClose,
+		Date:          quote.Date,
+	}
+	if quote.PreviousClose > 0 {
+		q.Change = quote.Price - quote.PreviousClose
+		q.ChangePercent = q.Change / quote.PreviousClose * 100
+	}
+
+	return q
+}
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS stock_prices;
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS stock_prices(
+    symbol varchar(20) NOT NULL,
+    date date NOT NULL,
+    open numeric(20, 8) NOT NULL,
+    high numeric(20, 8) NOT NULL,
+    low numeric(20, 8) NOT NULL,
+    close numeric(20, 8) NOT NULL,
+    volume bigint NOT NULL DEFAULT 0,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (symbol, date)
+);
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_portfolio_transactions_portfolio_symbol;
+DROP TABLE IF EXISTS portfolio_transactions;
\ No newline at end of file
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS portfolio_transactions(
+    id bigserial PRIMARY KEY,
+    portfolio_id bigint NOT NULL,
+    symbol varchar(20) NOT NULL,
+    type varchar(10) NOT NULL CHECK (type IN ('buy', 'sell', 'split', 'dividend')),
+    trade_date date NOT NULL,
+    quantity numeric(20, 8) NOT NULL,
+    price numeric(20, 8) NOT NULL DEFAULT 0,
+    fees numeric(20, 8) NOT NULL DEFAULT 0,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (portfolio_id) REFERENCES portfolios(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_portfolio_transactions_portfolio_symbol ON portfolio_transactions(portfolio_id, symbol, trade_date);
+
+-- Existing positions become opening buys so the ledger reproduces them.
+INSERT INTO portfolio_transactions (portfolio_id, symbol, type, trade_date, quantity, price)
+SELECT portfolio_id, symbol, 'buy', created_at::date, shares, average_price
+FROM portfolio_stocks;
@@ -0,0 +1,2 @@
+ALTER TABLE portfolios
+DROP COLUMN cost_basis_method;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE portfolios
+ADD COLUMN cost_basis_method varchar(10) NOT NULL DEFAULT 'fifo' CHECK (cost_basis_method IN ('fifo', 'lifo', 'average'));
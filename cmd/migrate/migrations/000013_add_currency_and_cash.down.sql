@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_portfolio_cash_portfolio_currency;
+DROP TABLE IF EXISTS portfolio_cash;
+
+ALTER TABLE portfolio_transactions
+DROP COLUMN currency;
+
+ALTER TABLE portfolio_stocks
+DROP COLUMN currency;
+
+ALTER TABLE portfolios
+DROP COLUMN base_currency;
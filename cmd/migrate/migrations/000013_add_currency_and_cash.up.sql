@@ -0,0 +1,22 @@
+ALTER TABLE portfolios
+ADD COLUMN base_currency char(3) NOT NULL DEFAULT 'USD';
+
+ALTER TABLE portfolio_stocks
+ADD COLUMN currency char(3) NOT NULL DEFAULT 'USD';
+
+ALTER TABLE portfolio_transactions
+ADD COLUMN currency char(3) NOT NULL DEFAULT 'USD';
+
+CREATE TABLE IF NOT EXISTS portfolio_cash(
+    id bigserial PRIMARY KEY,
+    portfolio_id bigint NOT NULL,
+    currency char(3) NOT NULL,
+    type varchar(20) NOT NULL CHECK (type IN ('deposit', 'withdrawal', 'dividend', 'interest', 'fee')),
+    amount numeric(20, 8) NOT NULL,
+    entry_date date NOT NULL,
+    description varchar(255) NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (portfolio_id) REFERENCES portfolios(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_portfolio_cash_portfolio_currency ON portfolio_cash(portfolio_id, currency);
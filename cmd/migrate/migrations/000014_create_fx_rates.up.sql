@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS fx_rates(
+    base char(3) NOT NULL,
+    quote char(3) NOT NULL,
+    rate_date date NOT NULL,
+    rate numeric(20, 10) NOT NULL CHECK (rate > 0),
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (base, quote, rate_date)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE portfolio_transactions
+DROP COLUMN IF EXISTS corporate_action_id;
+
+DROP TABLE IF EXISTS corporate_actions;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS corporate_actions(
+    id bigserial PRIMARY KEY,
+    symbol varchar(20) NOT NULL,
+    type varchar(20) NOT NULL CHECK (type IN ('dividend', 'split', 'ticker_change')),
+    ex_date date NOT NULL,
+    pay_date date NOT NULL,
+    amount numeric(20, 8) NOT NULL DEFAULT 0,
+    ratio numeric(20, 8) NOT NULL DEFAULT 0,
+    new_symbol varchar(20) NOT NULL DEFAULT '',
+    currency char(3) NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    UNIQUE (symbol, type, ex_date)
+);
+
+ALTER TABLE portfolio_transactions
+ADD COLUMN corporate_action_id bigint REFERENCES corporate_actions(id) ON DELETE SET NULL;
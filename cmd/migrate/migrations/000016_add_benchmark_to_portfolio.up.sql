@@ -0,0 +1,2 @@
+ALTER TABLE portfolios
+ADD COLUMN benchmark_symbol varchar(20) NOT NULL DEFAULT '';
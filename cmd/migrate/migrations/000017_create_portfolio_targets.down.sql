@@ -0,0 +1,5 @@
+ALTER TABLE portfolios
+DROP COLUMN IF EXISTS fractional_shares,
+DROP COLUMN IF EXISTS drift_tolerance;
+
+DROP TABLE IF EXISTS portfolio_targets;
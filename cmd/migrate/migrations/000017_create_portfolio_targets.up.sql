@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS portfolio_targets(
+    portfolio_id bigint NOT NULL,
+    symbol varchar(20) NOT NULL,
+    weight numeric(9, 6) NOT NULL CHECK (weight >= 0 AND weight <= 1),
+    PRIMARY KEY (portfolio_id, symbol),
+    FOREIGN KEY (portfolio_id) REFERENCES portfolios(id) ON DELETE CASCADE
+);
+
+ALTER TABLE portfolios
+ADD COLUMN fractional_shares boolean NOT NULL DEFAULT false,
+ADD COLUMN drift_tolerance numeric(9, 6) NOT NULL DEFAULT 0.05;
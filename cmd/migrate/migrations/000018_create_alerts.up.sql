@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS alerts(
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    kind varchar(20) NOT NULL CHECK (kind IN ('price', 'portfolio_change')),
+    symbol varchar(20) NOT NULL DEFAULT '',
+    portfolio_id bigint,
+    condition varchar(10) NOT NULL CHECK (condition IN ('above', 'below')),
+    threshold numeric(20, 8) NOT NULL,
+    state varchar(10) NOT NULL DEFAULT 'armed' CHECK (state IN ('armed', 'triggered')),
+    active boolean NOT NULL DEFAULT true,
+    last_value numeric(20, 8),
+    last_triggered_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (portfolio_id) REFERENCES portfolios(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_alerts_user ON alerts(user_id);
+CREATE INDEX IF NOT EXISTS idx_alerts_active ON alerts(active) WHERE active;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS watchlists(
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    name varchar(50) NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS watchlist_items(
+    id bigserial PRIMARY KEY,
+    watchlist_id bigint NOT NULL,
+    symbol varchar(20) NOT NULL,
+    note varchar(255) NOT NULL DEFAULT '',
+    position int NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (watchlist_id) REFERENCES watchlists(id) ON DELETE CASCADE,
+    UNIQUE (watchlist_id, symbol)
+);
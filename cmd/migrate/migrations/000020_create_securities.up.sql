@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS securities(
+    symbol varchar(20) PRIMARY KEY,
+    name varchar(255) NOT NULL,
+    exchange varchar(20) NOT NULL DEFAULT '',
+    asset_class varchar(20) NOT NULL DEFAULT '',
+    currency char(3) NOT NULL DEFAULT 'USD',
+    sector varchar(50) NOT NULL DEFAULT '',
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_securities_name ON securities(lower(name) text_pattern_ops);
@@ -0,0 +1,2 @@
+ALTER TABLE securities
+DROP COLUMN IF EXISTS country;
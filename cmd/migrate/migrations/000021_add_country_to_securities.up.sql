@@ -0,0 +1,2 @@
+ALTER TABLE securities
+ADD COLUMN country varchar(2) NOT NULL DEFAULT '';
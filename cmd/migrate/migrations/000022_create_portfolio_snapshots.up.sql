@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS portfolio_snapshots(
+    portfolio_id bigint NOT NULL,
+    snapshot_date date NOT NULL,
+    currency char(3) NOT NULL,
+    market_value numeric(20, 8) NOT NULL,
+    cost_basis numeric(20, 8) NOT NULL,
+    cash numeric(20, 8) NOT NULL,
+    total_value numeric(20, 8) NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (portfolio_id, snapshot_date),
+    FOREIGN KEY (portfolio_id) REFERENCES portfolios(id) ON DELETE CASCADE
+);
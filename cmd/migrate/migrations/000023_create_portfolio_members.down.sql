@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS portfolio_invitations;
+DROP TABLE IF EXISTS portfolio_members;
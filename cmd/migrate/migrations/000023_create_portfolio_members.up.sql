@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS portfolio_members(
+    portfolio_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    role varchar(10) NOT NULL CHECK (role IN ('viewer', 'editor', 'owner')),
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (portfolio_id, user_id),
+    FOREIGN KEY (portfolio_id) REFERENCES portfolios(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_portfolio_members_user ON portfolio_members(user_id);
+
+INSERT INTO portfolio_members (portfolio_id, user_id, role)
+SELECT id, user_id, 'owner'
+FROM portfolios
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS portfolio_invitations(
+    token bytea PRIMARY KEY,
+    portfolio_id bigint NOT NULL,
+    email citext NOT NULL,
+    role varchar(10) NOT NULL CHECK (role IN ('viewer', 'editor', 'owner')),
+    invited_by bigint NOT NULL,
+    expiry timestamp(0) with time zone NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    UNIQUE (portfolio_id, email),
+    FOREIGN KEY (portfolio_id) REFERENCES portfolios(id) ON DELETE CASCADE,
+    FOREIGN KEY (invited_by) REFERENCES users(id) ON DELETE CASCADE
+);
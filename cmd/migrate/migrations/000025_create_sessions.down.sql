@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS revoked_tokens;
+
+ALTER TABLE refresh_tokens
+DROP CONSTRAINT IF EXISTS fk_refresh_tokens_session;
+
+DROP TABLE IF EXISTS sessions;
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS sessions(
+    id uuid PRIMARY KEY,
+    user_id bigint NOT NULL,
+    user_agent varchar(255) NOT NULL DEFAULT '',
+    ip_address varchar(64) NOT NULL DEFAULT '',
+    expiry timestamp(0) with time zone NOT NULL,
+    revoked_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    last_used_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id);
+
+-- Every existing refresh token family becomes a session
+INSERT INTO sessions (id, user_id, expiry, revoked_at, created_at, last_used_at)
+SELECT family_id, user_id, MAX(expiry), MAX(revoked_at), MIN(created_at), MAX(created_at)
+FROM refresh_tokens
+GROUP BY family_id, user_id
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE refresh_tokens
+ADD CONSTRAINT fk_refresh_tokens_session FOREIGN KEY (family_id) REFERENCES sessions(id) ON DELETE CASCADE;
+
+CREATE TABLE IF NOT EXISTS revoked_tokens(
+    id varchar(64) PRIMARY KEY,
+    expiry timestamp(0) with time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expiry ON revoked_tokens(expiry);
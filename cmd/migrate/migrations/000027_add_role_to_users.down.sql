@@ -0,0 +1,5 @@
+ALTER TABLE users
+DROP COLUMN IF EXISTS deactivated_at;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS role;
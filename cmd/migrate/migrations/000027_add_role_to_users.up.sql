@@ -0,0 +1,5 @@
+ALTER TABLE users
+ADD COLUMN role varchar(10) NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'support', 'admin'));
+
+ALTER TABLE users
+ADD COLUMN deactivated_at timestamp(0) with time zone;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS user_mfa(
+    user_id bigint PRIMARY KEY,
+    secret varchar(64) NOT NULL,
+    enabled_at timestamp(0) with time zone,
+    last_used_step bigint NOT NULL DEFAULT 0,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes(
+    code bytea NOT NULL,
+    user_id bigint NOT NULL,
+    used_at timestamp(0) with time zone,
+    PRIMARY KEY (user_id, code),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
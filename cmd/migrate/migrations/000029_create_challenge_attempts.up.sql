@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS challenge_attempts(
+    id varchar(64) PRIMARY KEY,
+    attempts int NOT NULL DEFAULT 0,
+    expiry timestamp(0) with time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_challenge_attempts_expiry ON challenge_attempts(expiry);
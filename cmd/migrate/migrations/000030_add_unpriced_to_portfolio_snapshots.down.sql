@@ -0,0 +1,5 @@
+ALTER TABLE portfolio_snapshots
+DROP COLUMN IF EXISTS unconverted_currencies;
+
+ALTER TABLE portfolio_snapshots
+DROP COLUMN IF EXISTS unpriced_symbols;
@@ -0,0 +1,5 @@
+ALTER TABLE portfolio_snapshots
+ADD COLUMN unpriced_symbols text[] NOT NULL DEFAULT '{}';
+
+ALTER TABLE portfolio_snapshots
+ADD COLUMN unconverted_currencies text[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,216 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/fx"
+	"github.com/ecetinerdem/forseerv2/internal/mailer"
+	"github.com/ecetinerdem/forseerv2/internal/marketdata"
+	"github.com/ecetinerdem/forseerv2/internal/store"
+	"go.uber.org/zap"
+)
+
+var ErrNoValue = errors.New("no value to evaluate alert")
+
+type Config struct {
+	Interval  time.Duration
+	AlertsURL string
+	IsSandbox bool
+}
+
+// Evaluator periodically checks active alerts against the latest quotes and
+// emails the owner when one fires.
+type Evaluator struct {
+	store      *store.Storage
+	marketData marketdata.Provider
+	fx         *fx.Converter
+	mailer     mailer.Client
+	logger     *zap.SugaredLogger
+	config     Config
+}
+
+func NewEvaluator(store *store.Storage, marketData marketdata.Provider, fx *fx.Converter, mailer mailer.Client, logger *zap.SugaredLogger, config Config) *Evaluator {
+	return &Evaluator{
+		store:      store,
+		marketData: marketData,
+		fx:         fx,
+		mailer:     mailer,
+		logger:     logger,
+		config:     config,
+	}
+}
+
+// Run evaluates the alerts every interval until ctx is cancelled.
+func (e *Evaluator) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.config.Interval)
+	defer ticker.Stop()
+
+	e.logger.Infow("alert evaluator started", "interval", e.config.Interval.String())
+
+	for {
+		err := e.Evaluate(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			e.logger.Errorw("alert evaluation failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			e.logger.Info("alert evaluator stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Evaluate runs one pass over the active alerts. An armed alert whose
+// condition is met is triggered and notified; a triggered alert whose
+// condition has cleared is re-armed. An alert that fails is logged and the
+// pass moves on to the next one.
+func (e *Evaluator) Evaluate(ctx context.Context) error {
+	active, err := e.store.Alerts.GetActive(ctx)
+	if err != nil {
+		return err
+	}
+
+	quotes := map[string]*marketdata.Quote{}
+
+	for i := range active {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		alert := &active[i]
+
+		value, err := e.value(ctx, alert, quotes)
+		if err != nil {
+			e.logger.Warnw("alert skipped", "alert", alert.ID, "error", err)
+			continue
+		}
+
+		met := alert.Met(value)
+
+		switch {
+		case met && alert.State == store.AlertArmed:
+			fired, err := e.store.Alerts.Trigger(ctx, alert.ID, value)
+			if err != nil {
+				e.logger.Errorw("error triggering alert", "alert", alert.ID, "error", err)
+				continue
+			}
+			if fired {
+				e.notify(ctx, alert, value)
+			}
+		case !met && alert.State == store.AlertTriggered:
+			_, err := e.store.Alerts.Rearm(ctx, alert.ID, value)
+			if err != nil {
+				e.logger.Errorw("error re-arming alert", "alert", alert.ID, "error", err)
+				continue
+			}
+		}
+	}
+
+	return nil
+}
+
+func (e *Evaluator) value(ctx context.Context, alert *store.Alert, quotes map[string]*marketdata.Quote) (float64, error) {
+	switch alert.Kind {
+	case store.AlertPrice:
+		quote, err := e.quote(ctx, alert.Symbol, quotes)
+		if err != nil {
+			return 0, err
+		}
+		return quote.Price, nil
+	case store.AlertPortfolioChange:
+		if alert.PortfolioID == nil {
+			return 0, ErrNoValue
+		}
+		return e.portfolioChange(ctx, *alert.PortfolioID, alert.UserID, quotes)
+	}
+
+	return 0, fmt.Errorf("unknown alert kind %q", alert.Kind)
+}
+
+// portfolioChange is the percent change of the holdings' value since the
+// previous close, in the portfolio's base currency. A holding without a quote
+// or rate leaves no value, since the rest alone could look like a move.
+func (e *Evaluator) portfolioChange(ctx context.Context, portfolioID int64, userID int64, quotes map[string]*marketdata.Quote) (float64, error) {
+	portfolio, err := e.store.Portfolio.GetPortfolioByID(ctx, portfolioID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	var current, previous float64
+
+	for _, stock := range portfolio.Stocks {
+		quote, err := e.quote(ctx, stock.Symbol, quotes)
+		if err != nil {
+			return 0, fmt.Errorf("%w: quote for %s: %v", ErrNoValue, stock.Symbol, err)
+		}
+		if quote.PreviousClose == 0 {
+			return 0, fmt.Errorf("%w: no previous close for %s", ErrNoValue, stock.Symbol)
+		}
+
+		rate, err := e.fx.Rate(ctx, stock.Currency, portfolio.BaseCurrency, now)
+		if err != nil {
+			return 0, fmt.Errorf("%w: rate for %s: %v", ErrNoValue, stock.Currency, err)
+		}
+
+		current += stock.Shares * quote.Price * rate
+		previous += stock.Shares * quote.PreviousClose * rate
+	}
+
+	if previous == 0 {
+		return 0, ErrNoValue
+	}
+
+	return (current - previous) / previous * 100, nil
+}
+
+func (e *Evaluator) quote(ctx context.Context, symbol string, quotes map[string]*marketdata.Quote) (*marketdata.Quote, error) {
+	if quote, ok := quotes[symbol]; ok {
+		return quote, nil
+	}
+
+	quote, err := e.marketData.Quote(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	quotes[symbol] = quote
+	return quote, nil
+}
+
+func (e *Evaluator) notify(ctx context.Context, alert *store.Alert, value float64) {
+	user, err := e.store.Users.GetUserByID(ctx, alert.UserID)
+	if err != nil {
+		e.logger.Errorw("error loading alert owner", "alert", alert.ID, "error", err)
+		return
+	}
+
+	vars := struct {
+		Username    string
+		Description string
+		Value       string
+		Threshold   string
+		TriggeredAt string
+		AlertsURL   string
+	}{
+		Username:    user.Username,
+		Description: alert.Describe(),
+		Value:       fmt.Sprintf("%.2f", value),
+		Threshold:   fmt.Sprintf("%g", alert.Threshold),
+		TriggeredAt: time.Now().UTC().Format(time.RFC1123),
+		AlertsURL:   e.config.AlertsURL,
+	}
+
+	status, err := e.mailer.Send(mailer.AlertTemplate, user.Username, user.Email, vars, e.config.IsSandbox)
+	if err != nil {
+		e.logger.Errorw("error sending alert email", "alert", alert.ID, "error", err)
+		return
+	}
+
+	e.logger.Infow("alert email sent", "alert", alert.ID, "status code", status)
+}
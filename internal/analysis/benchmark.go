@@ -0,0 +1,76 @@
+package analysis
+
+import (
+	"math"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+// compareBenchmark measures the portfolio value series against a benchmark's
+// closes over the same dates. Alpha is Jensen's alpha and, like tracking
+// error, is annualized from daily returns. It returns nil when the two series
+// overlap on fewer than two dates.
+func compareBenchmark(dates []time.Time, values []float64, benchmark *store.StockHistory, riskFreeRate float64) *store.BenchmarkComparison {
+	portfolioValues := make([]float64, 0, len(dates))
+	benchmarkValues := make([]float64, 0, len(dates))
+	common := make([]time.Time, 0, len(dates))
+
+	// Carry the last benchmark close forward onto the portfolio's dates
+	i := 0
+	var last float64
+	for d, date := range dates {
+		for i < len(benchmark.Data) && !benchmark.Data[i].Date.After(date) {
+			last = benchmark.Data[i].Close
+			i++
+		}
+		if last <= 0 {
+			continue
+		}
+
+		common = append(common, date)
+		portfolioValues = append(portfolioValues, values[d])
+		benchmarkValues = append(benchmarkValues, last)
+	}
+
+	if len(common) < 2 {
+		return nil
+	}
+
+	portfolioReturns := dailyReturns(portfolioValues)
+	benchmarkReturns := dailyReturns(benchmarkValues)
+	dailyRiskFree := riskFreeRate / tradingDaysPerYear
+
+	comparison := &store.BenchmarkComparison{
+		Symbol: benchmark.Symbol,
+		Return: chainReturns(benchmarkReturns),
+		Series: make([]store.CumulativePoint, 0, len(common)),
+	}
+	comparison.AnnualizedReturn = math.Pow(1+comparison.Return, tradingDaysPerYear/float64(len(benchmarkReturns))) - 1
+	comparison.ExcessReturn = chainReturns(portfolioReturns) - comparison.Return
+
+	if variance := covariance(benchmarkReturns, benchmarkReturns); variance > 0 {
+		comparison.Beta = covariance(portfolioReturns, benchmarkReturns) / variance
+	}
+
+	comparison.Alpha = ((mean(portfolioReturns) - dailyRiskFree) - comparison.Beta*(mean(benchmarkReturns)-dailyRiskFree)) * tradingDaysPerYear
+
+	active := make([]float64, len(portfolioReturns))
+	for k := range portfolioReturns {
+		active[k] = portfolioReturns[k] - benchmarkReturns[k]
+	}
+	if te := stdDev(active); te > 0 {
+		comparison.TrackingError = te * math.Sqrt(tradingDaysPerYear)
+		comparison.InformationRatio = mean(active) * tradingDaysPerYear / comparison.TrackingError
+	}
+
+	for k, date := range common {
+		comparison.Series = append(comparison.Series, store.CumulativePoint{
+			Date:      date,
+			Portfolio: ratio(portfolioValues[k]-portfolioValues[0], portfolioValues[0]),
+			Benchmark: ratio(benchmarkValues[k]-benchmarkValues[0], benchmarkValues[0]),
+		})
+	}
+
+	return comparison
+}
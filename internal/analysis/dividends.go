@@ -0,0 +1,114 @@
+package analysis
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+type DividendPayment struct {
+	Symbol         string    `json:"symbol"`
+	Date           time.Time `json:"date"`
+	Shares         float64   `json:"shares"`
+	AmountPerShare float64   `json:"amount_per_share"`
+	Amount         float64   `json:"amount"`
+	Currency       string    `json:"currency"`
+}
+
+type SymbolIncome struct {
+	Symbol   string  `json:"symbol"`
+	Currency string  `json:"currency"`
+	Payments int     `json:"payments"`
+	Amount   float64 `json:"amount"`
+}
+
+type DividendReport struct {
+	PortfolioID           int64             `json:"portfolio_id"`
+	From                  time.Time         `json:"from"`
+	To                    time.Time         `json:"to"`
+	Currency              string            `json:"currency"`
+	Payments              []DividendPayment `json:"payments"`
+	BySymbol              []SymbolIncome    `json:"by_symbol"`
+	TotalIncome           float64           `json:"total_income"`
+	AnnualizedIncome      float64           `json:"annualized_income"`
+	Yield                 float64           `json:"yield"`
+	YieldOnCost           float64           `json:"yield_on_cost"`
+	UnconvertedCurrencies []string          `json:"unconverted_currencies"`
+}
+
+// DividendIncome sums the dividend transactions paid between from and to.
+// Income is converted to the base currency with rates (as in Value) and
+// annualized over the period; Yield and YieldOnCost relate it to the current
+// market value and cost basis of the holdings in valuation.
+func DividendIncome(valuation *Valuation, transactions []store.Transaction, from, to time.Time, rates map[string]float64) *DividendReport {
+	report := &DividendReport{
+		PortfolioID:           valuation.PortfolioID,
+		From:                  from,
+		To:                    to,
+		Currency:              valuation.Currency,
+		Payments:              []DividendPayment{},
+		BySymbol:              []SymbolIncome{},
+		UnconvertedCurrencies: []string{},
+	}
+
+	bySymbol := map[string]*SymbolIncome{}
+	unconverted := map[string]bool{}
+
+	for _, t := range transactions {
+		if t.Type != store.TransactionDividend || t.TradeDate.Before(from) || t.TradeDate.After(to) {
+			continue
+		}
+
+		payment := DividendPayment{
+			Symbol:         t.Symbol,
+			Date:           t.TradeDate,
+			Shares:         t.Quantity,
+			AmountPerShare: t.Price,
+			Amount:         t.Quantity * t.Price,
+			Currency:       t.Currency,
+		}
+		report.Payments = append(report.Payments, payment)
+
+		key := t.Symbol + t.Currency
+		if _, ok := bySymbol[key]; !ok {
+			bySymbol[key] = &SymbolIncome{Symbol: t.Symbol, Currency: t.Currency}
+		}
+		bySymbol[key].Payments++
+		bySymbol[key].Amount += payment.Amount
+
+		rate := 1.0
+		if t.Currency != report.Currency {
+			r, ok := rates[t.Currency]
+			if !ok {
+				unconverted[t.Currency] = true
+				continue
+			}
+			rate = r
+		}
+		report.TotalIncome += payment.Amount * rate
+	}
+
+	sort.Slice(report.Payments, func(i, j int) bool {
+		return report.Payments[i].Date.Before(report.Payments[j].Date)
+	})
+
+	for _, income := range bySymbol {
+		report.BySymbol = append(report.BySymbol, *income)
+	}
+	sort.Slice(report.BySymbol, func(i, j int) bool {
+		return report.BySymbol[i].Amount > report.BySymbol[j].Amount
+	})
+
+	for currency := range unconverted {
+		report.UnconvertedCurrencies = append(report.UnconvertedCurrencies, currency)
+	}
+	sort.Strings(report.UnconvertedCurrencies)
+
+	days := to.Sub(from).Hours()/24 + 1
+	report.AnnualizedIncome = report.TotalIncome * 365 / days
+	report.Yield = percent(report.AnnualizedIncome, valuation.MarketValue)
+	report.YieldOnCost = percent(report.AnnualizedIncome, valuation.CostBasis)
+
+	return report
+}
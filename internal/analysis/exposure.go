@@ -0,0 +1,162 @@
+package analysis
+
+import (
+	"sort"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+const (
+	Unclassified = "unclassified"
+	CashBucket   = "cash"
+)
+
+type ExposureBucket struct {
+	Key         string  `json:"key"`
+	MarketValue float64 `json:"market_value"`
+	Weight      float64 `json:"weight"`
+}
+
+type Exposure struct {
+	PortfolioIDs          []int64          `json:"portfolio_ids"`
+	Currency              string           `json:"currency"`
+	TotalValue            float64          `json:"total_value"`
+	BySector              []ExposureBucket `json:"by_sector"`
+	ByAssetClass          []ExposureBucket `json:"by_asset_class"`
+	ByCountry             []ExposureBucket `json:"by_country"`
+	ByCurrency            []ExposureBucket `json:"by_currency"`
+	UnclassifiedSymbols   []string         `json:"unclassified_symbols"`
+	UnpricedSymbols       []string         `json:"unpriced_symbols"`
+	UnconvertedCurrencies []string         `json:"unconverted_currencies"`
+}
+
+// Exposures aggregates the market value of one or more valued portfolios by
+// the sector, asset class and country of their securities and by currency.
+// Values are in currency; rates maps each portfolio base currency to it. Cash
+// counts as the "cash" sector and asset class, and anything without reference
+// data is "unclassified". Holdings of a portfolio whose base currency cannot
+// be converted are reported as unpriced.
+func Exposures(valuations []*Valuation, securities map[string]store.Security, currency string, rates map[string]float64) *Exposure {
+	exposure := &Exposure{
+		PortfolioIDs:          make([]int64, 0, len(valuations)),
+		Currency:              currency,
+		UnclassifiedSymbols:   []string{},
+		UnpricedSymbols:       []string{},
+		UnconvertedCurrencies: []string{},
+	}
+
+	sectors := map[string]float64{}
+	assetClasses := map[string]float64{}
+	countries := map[string]float64{}
+	currencies := map[string]float64{}
+
+	unclassified := map[string]bool{}
+	unpriced := map[string]bool{}
+	unconverted := map[string]bool{}
+
+	for _, v := range valuations {
+		exposure.PortfolioIDs = append(exposure.PortfolioIDs, v.PortfolioID)
+
+		rate := 1.0
+		if v.Currency != currency {
+			r, ok := rates[v.Currency]
+			if !ok {
+				unconverted[v.Currency] = true
+				for _, h := range v.Holdings {
+					unpriced[h.Symbol] = true
+				}
+				for _, symbol := range v.UnpricedSymbols {
+					unpriced[symbol] = true
+				}
+				continue
+			}
+			rate = r
+		}
+
+		for _, symbol := range v.UnpricedSymbols {
+			unpriced[symbol] = true
+		}
+		for _, c := range v.UnconvertedCurrencies {
+			unconverted[c] = true
+		}
+
+		for _, h := range v.Holdings {
+			if !h.Priced || h.FXRate == 0 {
+				continue
+			}
+
+			value := h.MarketValueBase * rate
+			exposure.TotalValue += value
+			currencies[h.Currency] += value
+
+			security, ok := securities[h.Symbol]
+			if !ok {
+				unclassified[h.Symbol] = true
+			}
+
+			sectors[orUnclassified(security.Sector)] += value
+			assetClasses[orUnclassified(security.AssetClass)] += value
+			countries[orUnclassified(security.Country)] += value
+		}
+
+		for _, t := range v.ByCurrency {
+			if !t.Converted || t.Cash == 0 {
+				continue
+			}
+
+			value := t.Cash * t.FXRate * rate
+			exposure.TotalValue += value
+			currencies[t.Currency] += value
+			sectors[CashBucket] += value
+			assetClasses[CashBucket] += value
+			countries[Unclassified] += value
+		}
+	}
+
+	exposure.BySector = buckets(sectors, exposure.TotalValue)
+	exposure.ByAssetClass = buckets(assetClasses, exposure.TotalValue)
+	exposure.ByCountry = buckets(countries, exposure.TotalValue)
+	exposure.ByCurrency = buckets(currencies, exposure.TotalValue)
+
+	exposure.UnclassifiedSymbols = sortedKeys(unclassified)
+	exposure.UnpricedSymbols = sortedKeys(unpriced)
+	exposure.UnconvertedCurrencies = sortedKeys(unconverted)
+
+	return exposure
+}
+
+func orUnclassified(key string) string {
+	if key == "" {
+		return Unclassified
+	}
+	return key
+}
+
+func buckets(values map[string]float64, total float64) []ExposureBucket {
+	result := make([]ExposureBucket, 0, len(values))
+	for key, value := range values {
+		result = append(result, ExposureBucket{
+			Key:         key,
+			MarketValue: value,
+			Weight:      ratio(value, total),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].MarketValue != result[j].MarketValue {
+			return result[i].MarketValue > result[j].MarketValue
+		}
+		return result[i].Key < result[j].Key
+	})
+
+	return result
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,319 @@
+package analysis
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+var ErrInsufficientData = errors.New("not enough price history to analyze portfolio")
+
+// RatesFunc returns the amount of base currency one unit of each currency buys
+// on date. Currencies without a rate are left out, as Value expects.
+type RatesFunc func(currencies []string, date time.Time) (map[string]float64, error)
+
+type series struct {
+	dates []time.Time
+	// values is a growth index that starts at the first market value and
+	// moves only with returns, so buys and sells do not show up as gains
+	values        []float64
+	returns       []float64
+	startValue    float64
+	endValue      float64
+	contributions map[string]*contribution
+	unpriced      map[string]bool
+	unconverted   map[string]bool
+}
+
+type contribution struct {
+	startWeight float64
+	growth      float64
+	total       float64
+}
+
+// Analyze computes performance and risk metrics for a portfolio over the given
+// price histories. The ledger is replayed day by day, every holding is valued
+// in the base currency at that day's rate and daily returns exclude the
+// day's trades. When a benchmark history is given the portfolio is also
+// compared against it. transactions must be sorted by trade date.
+func Analyze(portfolio *store.Portfolio, transactions []store.Transaction, histories []store.StockHistory, benchmark *store.StockHistory, rates RatesFunc, riskFreeRate float64) (*store.PortfolioAnalysis, error) {
+	s, err := buildSeries(portfolio.BaseCurrency, portfolio.CostBasisMethod, transactions, histories, rates)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.values) < 2 {
+		return nil, ErrInsufficientData
+	}
+
+	returns := s.returns
+	dailyRiskFree := riskFreeRate / tradingDaysPerYear
+
+	result := &store.PortfolioAnalysis{
+		PortfolioID:           portfolio.ID,
+		Currency:              portfolio.BaseCurrency,
+		From:                  s.dates[0],
+		To:                    s.dates[len(s.dates)-1],
+		Stocks:                histories,
+		StartValue:            s.startValue,
+		EndValue:              s.endValue,
+		TimeWeightedReturn:    chainReturns(returns),
+		AnnualizedVolatility:  stdDev(returns) * math.Sqrt(tradingDaysPerYear),
+		MaxDrawdown:           maxDrawdown(s.values),
+		Contributions:         []store.HoldingContribution{},
+		UnpricedSymbols:       sortedKeys(s.unpriced),
+		UnconvertedCurrencies: sortedKeys(s.unconverted),
+	}
+
+	result.AnnualizedReturn = math.Pow(1+result.TimeWeightedReturn, tradingDaysPerYear/float64(len(returns))) - 1
+
+	excess := mean(returns) - dailyRiskFree
+	if sd := stdDev(returns); sd > 0 {
+		result.SharpeRatio = excess / sd * math.Sqrt(tradingDaysPerYear)
+	}
+	if dd := downsideDev(returns, dailyRiskFree); dd > 0 {
+		result.SortinoRatio = excess / dd * math.Sqrt(tradingDaysPerYear)
+	}
+
+	for symbol, c := range s.contributions {
+		result.Contributions = append(result.Contributions, store.HoldingContribution{
+			Symbol:       symbol,
+			StartWeight:  c.startWeight,
+			Return:       c.growth - 1,
+			Contribution: c.total,
+		})
+	}
+
+	sort.Slice(result.Contributions, func(i, j int) bool {
+		if result.Contributions[i].Contribution != result.Contributions[j].Contribution {
+			return result.Contributions[i].Contribution > result.Contributions[j].Contribution
+		}
+		return result.Contributions[i].Symbol < result.Contributions[j].Symbol
+	})
+
+	if benchmark != nil {
+		result.Benchmark = compareBenchmark(s.dates, s.values, benchmark, riskFreeRate)
+	}
+
+	return result, nil
+}
+
+// HeldSymbols lists the symbols the ledger holds at some point between from
+// and to, which are the ones Analyze needs histories for. transactions must
+// be sorted by trade date.
+func HeldSymbols(transactions []store.Transaction, method string, from, to time.Time) ([]string, error) {
+	book := store.NewLotBook(method)
+	next := 0
+	for ; next < len(transactions) && transactions[next].TradeDate.Before(from); next++ {
+		_, err := book.Apply(transactions[next])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	seen := map[string]bool{}
+	symbols := []string{}
+	for _, stock := range book.Positions() {
+		seen[stock.Symbol] = true
+		symbols = append(symbols, stock.Symbol)
+	}
+
+	for _, t := range transactions[next:] {
+		if t.TradeDate.After(to) {
+			break
+		}
+		if t.Type != store.TransactionBuy || seen[t.Symbol] {
+			continue
+		}
+		seen[t.Symbol] = true
+		symbols = append(symbols, t.Symbol)
+	}
+
+	return symbols, nil
+}
+
+// buildSeries walks the dates of the histories, applying each day's trades
+// and carrying the last known close forward. It starts once every held symbol
+// that has a history has a price. A day's return compares the holdings carried
+// overnight at both days' prices and rates, so holdings that cannot be valued
+// on either day are left out of it rather than showing up as a loss or gain.
+func buildSeries(base, method string, transactions []store.Transaction, histories []store.StockHistory, rates RatesFunc) (*series, error) {
+	bySymbol := make(map[string][]store.DailyData, len(histories))
+	dateSet := map[time.Time]struct{}{}
+
+	for _, h := range histories {
+		bySymbol[h.Symbol] = h.Data
+		for _, d := range h.Data {
+			dateSet[d.Date] = struct{}{}
+		}
+	}
+
+	dates := make([]time.Time, 0, len(dateSet))
+	for d := range dateSet {
+		dates = append(dates, d)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	s := &series{
+		contributions: map[string]*contribution{},
+		unpriced:      map[string]bool{},
+		unconverted:   map[string]bool{},
+	}
+
+	book := store.NewLotBook(method)
+	next := 0
+	cursor := make(map[string]int, len(bySymbol))
+	closes := make(map[string]float64, len(bySymbol))
+
+	var held []store.Stock
+	var units map[string]float64
+
+	for _, date := range dates {
+		for symbol, data := range bySymbol {
+			i := cursor[symbol]
+			for i < len(data) && !data[i].Date.After(date) {
+				closes[symbol] = data[i].Close
+				i++
+			}
+			cursor[symbol] = i
+		}
+
+		// Shares carried overnight, with today's splits applied
+		overnight := make(map[string]float64, len(held))
+		for _, stock := range held {
+			overnight[stock.Symbol] = stock.Shares
+		}
+
+		end := date.AddDate(0, 0, 1)
+		for ; next < len(transactions) && transactions[next].TradeDate.Before(end); next++ {
+			t := transactions[next]
+			_, err := book.Apply(t)
+			if err != nil {
+				return nil, err
+			}
+			if t.Type == store.TransactionSplit {
+				overnight[t.Symbol] *= t.Quantity
+			}
+		}
+		positions := book.Positions()
+
+		// Price a share of every holding of yesterday or today in base currency
+		both := make([]store.Stock, 0, len(held)+len(positions))
+		both = append(both, held...)
+		both = append(both, positions...)
+
+		currencies := make([]string, 0, len(both))
+		for _, stock := range both {
+			currencies = append(currencies, stock.Currency)
+		}
+		dayRates, err := rates(currencies, date)
+		if err != nil {
+			return nil, err
+		}
+
+		dayUnits := map[string]float64{}
+		unpriced := []string{}
+		unconverted := []string{}
+		for _, stock := range both {
+			if _, ok := dayUnits[stock.Symbol]; ok {
+				continue
+			}
+
+			price, ok := closes[stock.Symbol]
+			if !ok {
+				unpriced = append(unpriced, stock.Symbol)
+				continue
+			}
+
+			rate := 1.0
+			if stock.Currency != base {
+				rate, ok = dayRates[stock.Currency]
+				if !ok {
+					unconverted = append(unconverted, stock.Currency)
+					continue
+				}
+			}
+
+			dayUnits[stock.Symbol] = price * rate
+		}
+
+		var value float64
+		for _, stock := range positions {
+			value += stock.Shares * dayUnits[stock.Symbol]
+		}
+
+		if len(s.values) == 0 {
+			ready := value > 0
+			for _, stock := range positions {
+				if _, ok := closes[stock.Symbol]; !ok && len(bySymbol[stock.Symbol]) > 0 {
+					ready = false
+				}
+			}
+
+			if ready {
+				s.startValue = value
+				s.dates = append(s.dates, date)
+				s.values = append(s.values, value)
+
+				for _, stock := range positions {
+					if unit, ok := dayUnits[stock.Symbol]; ok {
+						s.contributions[stock.Symbol] = &contribution{
+							startWeight: stock.Shares * unit / value,
+							growth:      1,
+						}
+					}
+				}
+			}
+		} else {
+			var before, after float64
+			for _, stock := range held {
+				prev, ok := units[stock.Symbol]
+				unit, ok2 := dayUnits[stock.Symbol]
+				if !ok || !ok2 {
+					continue
+				}
+				before += stock.Shares * prev
+				after += overnight[stock.Symbol] * unit
+			}
+
+			r := ratio(after-before, before)
+			for _, stock := range held {
+				prev, ok := units[stock.Symbol]
+				unit, ok2 := dayUnits[stock.Symbol]
+				if !ok || !ok2 || before == 0 {
+					continue
+				}
+
+				was, now := stock.Shares*prev, overnight[stock.Symbol]*unit
+				c, ok := s.contributions[stock.Symbol]
+				if !ok {
+					c = &contribution{growth: 1}
+					s.contributions[stock.Symbol] = c
+				}
+				c.growth *= 1 + ratio(now-was, was)
+				c.total += (now - was) / before
+			}
+
+			s.dates = append(s.dates, date)
+			s.returns = append(s.returns, r)
+			s.values = append(s.values, s.values[len(s.values)-1]*(1+r))
+		}
+
+		if len(s.values) > 0 {
+			s.endValue = value
+			for _, symbol := range unpriced {
+				s.unpriced[symbol] = true
+			}
+			for _, currency := range unconverted {
+				s.unconverted[currency] = true
+			}
+		}
+
+		held = positions
+		units = dayUnits
+	}
+
+	return s, nil
+}
@@ -0,0 +1,30 @@
+package analysis
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidPeriod = errors.New("invalid period, expected one of 1m, 3m, 6m, ytd, 1y, 3y, 5y")
+
+// PeriodStart returns the first day covered by a period such as 1y ending at now.
+func PeriodStart(period string, now time.Time) (time.Time, error) {
+	switch period {
+	case "1m":
+		return now.AddDate(0, -1, 0), nil
+	case "3m":
+		return now.AddDate(0, -3, 0), nil
+	case "6m":
+		return now.AddDate(0, -6, 0), nil
+	case "ytd":
+		return time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, now.Location()), nil
+	case "1y":
+		return now.AddDate(-1, 0, 0), nil
+	case "3y":
+		return now.AddDate(-3, 0, 0), nil
+	case "5y":
+		return now.AddDate(-5, 0, 0), nil
+	default:
+		return time.Time{}, ErrInvalidPeriod
+	}
+}
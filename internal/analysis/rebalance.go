@@ -0,0 +1,205 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+const (
+	OrderBuy  = "buy"
+	OrderSell = "sell"
+)
+
+type RebalanceOrder struct {
+	Symbol   string  `json:"symbol"`
+	Side     string  `json:"side"`
+	Shares   float64 `json:"shares"`
+	Price    float64 `json:"price"`
+	Currency string  `json:"currency"`
+	Amount   float64 `json:"amount"`
+}
+
+type AllocationDrift struct {
+	Symbol          string  `json:"symbol"`
+	CurrentWeight   float64 `json:"current_weight"`
+	TargetWeight    float64 `json:"target_weight"`
+	Drift           float64 `json:"drift"`
+	ResultingWeight float64 `json:"resulting_weight"`
+}
+
+type RebalancePlan struct {
+	PortfolioID      int64             `json:"portfolio_id"`
+	Currency         string            `json:"currency"`
+	NewCash          float64           `json:"new_cash"`
+	TotalValue       float64           `json:"total_value"`
+	DriftTolerance   float64           `json:"drift_tolerance"`
+	FractionalShares bool              `json:"fractional_shares"`
+	WithinTolerance  bool              `json:"within_tolerance"`
+	Orders           []RebalanceOrder  `json:"orders"`
+	Allocations      []AllocationDrift `json:"allocations"`
+	CashRemaining    float64           `json:"cash_remaining"`
+	UnpricedSymbols  []string          `json:"unpriced_symbols"`
+}
+
+type rebalancePosition struct {
+	symbol   string
+	currency string
+	shares   float64
+	price    float64
+	fxRate   float64
+	value    float64
+	target   float64
+	trade    float64
+}
+
+// Rebalance proposes the orders that bring the valued holdings, the cash
+// already held and newCash (in the base currency) to the target weights.
+// Nothing is traded while every holding is within the drift tolerance and no
+// cash is added. Sells are sized first and fund the buys, largest shortfall
+// first; without fractional shares quantities are rounded towards zero,
+// leaving the rest as cash.
+// prices holds the latest price of target symbols not yet held, which are
+// assumed to trade in the base currency.
+func Rebalance(valuation *Valuation, allocation *store.TargetAllocation, prices map[string]float64, newCash float64) *RebalancePlan {
+	plan := &RebalancePlan{
+		PortfolioID:      valuation.PortfolioID,
+		Currency:         valuation.Currency,
+		NewCash:          newCash,
+		TotalValue:       valuation.MarketValue + valuation.Cash + newCash,
+		DriftTolerance:   allocation.DriftTolerance,
+		FractionalShares: allocation.FractionalShares,
+		Orders:           []RebalanceOrder{},
+		Allocations:      []AllocationDrift{},
+		UnpricedSymbols:  []string{},
+	}
+
+	positions := []*rebalancePosition{}
+	bySymbol := map[string]*rebalancePosition{}
+
+	for _, h := range valuation.Holdings {
+		if !h.Priced || h.FXRate == 0 {
+			plan.UnpricedSymbols = append(plan.UnpricedSymbols, h.Symbol)
+			continue
+		}
+
+		p := &rebalancePosition{
+			symbol:   h.Symbol,
+			currency: h.Currency,
+			shares:   h.Shares,
+			price:    h.Price,
+			fxRate:   h.FXRate,
+			value:    h.MarketValueBase,
+		}
+		positions = append(positions, p)
+		bySymbol[h.Symbol] = p
+	}
+
+	for _, t := range allocation.Targets {
+		if p, ok := bySymbol[t.Symbol]; ok {
+			p.target = t.Weight
+			continue
+		}
+
+		price, ok := prices[t.Symbol]
+		if !ok || price <= 0 {
+			plan.UnpricedSymbols = append(plan.UnpricedSymbols, t.Symbol)
+			continue
+		}
+
+		p := &rebalancePosition{
+			symbol:   t.Symbol,
+			currency: valuation.Currency,
+			price:    price,
+			fxRate:   1,
+			target:   t.Weight,
+		}
+		positions = append(positions, p)
+		bySymbol[t.Symbol] = p
+	}
+
+	plan.WithinTolerance = newCash == 0
+	for _, p := range positions {
+		if math.Abs(ratio(p.value, plan.TotalValue)-p.target) > allocation.DriftTolerance {
+			plan.WithinTolerance = false
+		}
+	}
+
+	cash := valuation.Cash + newCash
+	if !plan.WithinTolerance {
+		buys := []*rebalancePosition{}
+
+		for _, p := range positions {
+			unitCost := p.price * p.fxRate
+			p.trade = (p.target*plan.TotalValue - p.value) / unitCost
+			if !allocation.FractionalShares {
+				p.trade = math.Trunc(p.trade)
+			}
+
+			switch {
+			case p.trade < 0:
+				p.trade = math.Max(p.trade, -p.shares)
+				cash -= p.trade * unitCost
+			case p.trade > 0:
+				buys = append(buys, p)
+			}
+		}
+
+		sort.Slice(buys, func(i, j int) bool {
+			return buys[i].target*plan.TotalValue-buys[i].value > buys[j].target*plan.TotalValue-buys[j].value
+		})
+
+		for _, p := range buys {
+			unitCost := p.price * p.fxRate
+			if p.trade*unitCost > cash {
+				p.trade = math.Max(cash/unitCost, 0)
+				if !allocation.FractionalShares {
+					p.trade = math.Floor(p.trade)
+				}
+			}
+			cash -= p.trade * unitCost
+		}
+	}
+	plan.CashRemaining = cash
+
+	for _, p := range positions {
+		unitCost := p.price * p.fxRate
+
+		if p.trade != 0 {
+			order := RebalanceOrder{
+				Symbol:   p.symbol,
+				Side:     OrderBuy,
+				Shares:   math.Abs(p.trade),
+				Price:    p.price,
+				Currency: p.currency,
+				Amount:   math.Abs(p.trade) * unitCost,
+			}
+			if p.trade < 0 {
+				order.Side = OrderSell
+			}
+			plan.Orders = append(plan.Orders, order)
+		}
+
+		current := ratio(p.value, plan.TotalValue)
+		plan.Allocations = append(plan.Allocations, AllocationDrift{
+			Symbol:          p.symbol,
+			CurrentWeight:   current,
+			TargetWeight:    p.target,
+			Drift:           current - p.target,
+			ResultingWeight: ratio(p.value+p.trade*unitCost, plan.TotalValue),
+		})
+	}
+
+	sort.Slice(plan.Orders, func(i, j int) bool {
+		if plan.Orders[i].Side != plan.Orders[j].Side {
+			return plan.Orders[i].Side == OrderSell
+		}
+		return plan.Orders[i].Amount > plan.Orders[j].Amount
+	})
+	sort.Slice(plan.Allocations, func(i, j int) bool {
+		return math.Abs(plan.Allocations[i].Drift) > math.Abs(plan.Allocations[j].Drift)
+	})
+
+	return plan
+}
@@ -0,0 +1,94 @@
+package analysis
+
+import "math"
+
+const tradingDaysPerYear = 252
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// stdDev is the sample standard deviation.
+func stdDev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+
+	m := mean(values)
+	var sum float64
+	for _, v := range values {
+		sum += (v - m) * (v - m)
+	}
+	return math.Sqrt(sum / float64(len(values)-1))
+}
+
+// covariance is the sample covariance of two equally long series.
+func covariance(a, b []float64) float64 {
+	if len(a) < 2 || len(a) != len(b) {
+		return 0
+	}
+
+	ma, mb := mean(a), mean(b)
+	var sum float64
+	for i := range a {
+		sum += (a[i] - ma) * (b[i] - mb)
+	}
+	return sum / float64(len(a)-1)
+}
+
+// downsideDev measures deviation below the target return only.
+func downsideDev(values []float64, target float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, v := range values {
+		if v < target {
+			sum += (v - target) * (v - target)
+		}
+	}
+	return math.Sqrt(sum / float64(len(values)))
+}
+
+func dailyReturns(values []float64) []float64 {
+	if len(values) < 2 {
+		return []float64{}
+	}
+
+	returns := make([]float64, 0, len(values)-1)
+	for i := 1; i < len(values); i++ {
+		returns = append(returns, ratio(values[i]-values[i-1], values[i-1]))
+	}
+	return returns
+}
+
+// chainReturns links periodic returns into a cumulative (time-weighted) return.
+func chainReturns(returns []float64) float64 {
+	growth := 1.0
+	for _, r := range returns {
+		growth *= 1 + r
+	}
+	return growth - 1
+}
+
+func maxDrawdown(values []float64) float64 {
+	var peak, drawdown float64
+	for _, v := range values {
+		if v > peak {
+			peak = v
+		}
+		if peak > 0 {
+			drawdown = math.Max(drawdown, (peak-v)/peak)
+		}
+	}
+	return drawdown
+}
@@ -0,0 +1,160 @@
+package analysis
+
+import (
+	"sort"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+type PortfolioTotal struct {
+	PortfolioID  int64   `json:"portfolio_id"`
+	Name         string  `json:"name"`
+	BaseCurrency string  `json:"base_currency"`
+	TotalValue   float64 `json:"total_value"`
+	Weight       float64 `json:"weight"`
+}
+
+type CombinedHolding struct {
+	Symbol               string  `json:"symbol"`
+	Currency             string  `json:"currency"`
+	Shares               float64 `json:"shares"`
+	Price                float64 `json:"price"`
+	MarketValue          float64 `json:"market_value"`
+	CostBasis            float64 `json:"cost_basis"`
+	UnrealizedPnL        float64 `json:"unrealized_pnl"`
+	UnrealizedPnLPercent float64 `json:"unrealized_pnl_percent"`
+	Weight               float64 `json:"weight"`
+	PortfolioIDs         []int64 `json:"portfolio_ids"`
+}
+
+type Summary struct {
+	Currency              string            `json:"currency"`
+	PortfolioCount        int               `json:"portfolio_count"`
+	Portfolios            []PortfolioTotal  `json:"portfolios"`
+	Holdings              []CombinedHolding `json:"holdings"`
+	MarketValue           float64           `json:"market_value"`
+	CostBasis             float64           `json:"cost_basis"`
+	UnrealizedPnL         float64           `json:"unrealized_pnl"`
+	UnrealizedPnLPercent  float64           `json:"unrealized_pnl_percent"`
+	Cash                  float64           `json:"cash"`
+	TotalValue            float64           `json:"total_value"`
+	UnpricedSymbols       []string          `json:"unpriced_symbols"`
+	UnconvertedCurrencies []string          `json:"unconverted_currencies"`
+}
+
+// holdingKey identifies a combined holding. A symbol traded in two currencies
+// stays two holdings so shares and prices are never mixed.
+type holdingKey struct {
+	symbol   string
+	currency string
+}
+
+// Summarize combines valued portfolios into one view in currency. The same
+// symbol held in the same currency in several portfolios becomes one holding;
+// values and weights are in currency, with rates mapping each portfolio base
+// currency to it. valuations must be in the same order as portfolios.
+func Summarize(portfolios []*store.Portfolio, valuations []*Valuation, currency string, rates map[string]float64) *Summary {
+	summary := &Summary{
+		Currency:       currency,
+		PortfolioCount: len(portfolios),
+		Portfolios:     make([]PortfolioTotal, 0, len(portfolios)),
+		Holdings:       []CombinedHolding{},
+	}
+
+	holdings := map[holdingKey]*CombinedHolding{}
+	unpriced := map[string]bool{}
+	unconverted := map[string]bool{}
+
+	for i, v := range valuations {
+		total := PortfolioTotal{
+			PortfolioID:  portfolios[i].ID,
+			Name:         portfolios[i].Name,
+			BaseCurrency: v.Currency,
+		}
+
+		rate := 1.0
+		if v.Currency != currency {
+			r, ok := rates[v.Currency]
+			if !ok {
+				unconverted[v.Currency] = true
+				for _, h := range v.Holdings {
+					unpriced[h.Symbol] = true
+				}
+				for _, symbol := range v.UnpricedSymbols {
+					unpriced[symbol] = true
+				}
+				summary.Portfolios = append(summary.Portfolios, total)
+				continue
+			}
+			rate = r
+		}
+
+		for _, symbol := range v.UnpricedSymbols {
+			unpriced[symbol] = true
+		}
+		for _, c := range v.UnconvertedCurrencies {
+			unconverted[c] = true
+		}
+
+		for _, h := range v.Holdings {
+			if !h.Priced || h.FXRate == 0 {
+				continue
+			}
+
+			key := holdingKey{symbol: h.Symbol, currency: h.Currency}
+			combined, ok := holdings[key]
+			if !ok {
+				combined = &CombinedHolding{
+					Symbol:       h.Symbol,
+					Currency:     h.Currency,
+					Price:        h.Price,
+					PortfolioIDs: []int64{},
+				}
+				holdings[key] = combined
+			}
+
+			combined.Shares += h.Shares
+			combined.MarketValue += h.MarketValueBase * rate
+			combined.CostBasis += h.CostBasis * h.FXRate * rate
+			combined.PortfolioIDs = append(combined.PortfolioIDs, v.PortfolioID)
+		}
+
+		total.TotalValue = v.TotalValue * rate
+		summary.MarketValue += v.MarketValue * rate
+		summary.CostBasis += v.CostBasis * rate
+		summary.Cash += v.Cash * rate
+		summary.Portfolios = append(summary.Portfolios, total)
+	}
+
+	summary.TotalValue = summary.MarketValue + summary.Cash
+	summary.UnrealizedPnL = summary.MarketValue - summary.CostBasis
+	summary.UnrealizedPnLPercent = percent(summary.UnrealizedPnL, summary.CostBasis)
+
+	for i := range summary.Portfolios {
+		summary.Portfolios[i].Weight = ratio(summary.Portfolios[i].TotalValue, summary.TotalValue)
+	}
+	sort.Slice(summary.Portfolios, func(i, j int) bool {
+		return summary.Portfolios[i].TotalValue > summary.Portfolios[j].TotalValue
+	})
+
+	for _, h := range holdings {
+		h.UnrealizedPnL = h.MarketValue - h.CostBasis
+		h.UnrealizedPnLPercent = percent(h.UnrealizedPnL, h.CostBasis)
+		h.Weight = ratio(h.MarketValue, summary.MarketValue)
+		summary.Holdings = append(summary.Holdings, *h)
+	}
+	sort.Slice(summary.Holdings, func(i, j int) bool {
+		if summary.Holdings[i].MarketValue != summary.Holdings[j].MarketValue {
+			return summary.Holdings[i].MarketValue > summary.Holdings[j].MarketValue
+		}
+		if summary.Holdings[i].Symbol != summary.Holdings[j].Symbol {
+			return summary.Holdings[i].Symbol < summary.Holdings[j].Symbol
+		}
+		return summary.Holdings[i].Currency < summary.Holdings[j].Currency
+	})
+
+	summary.UnpricedSymbols = sortedKeys(unpriced)
+	summary.UnconvertedCurrencies = sortedKeys(unconverted)
+
+	return summary
+}
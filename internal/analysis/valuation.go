@@ -0,0 +1,166 @@
+package analysis
+
+import (
+	"sort"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+type HoldingValuation struct {
+	Symbol               string  `json:"symbol"`
+	Currency             string  `json:"currency"`
+	Shares               float64 `json:"shares"`
+	AveragePrice         float64 `json:"average_price"`
+	Price                float64 `json:"price"`
+	Priced               bool    `json:"priced"`
+	MarketValue          float64 `json:"market_value"`
+	CostBasis            float64 `json:"cost_basis"`
+	UnrealizedPnL        float64 `json:"unrealized_pnl"`
+	UnrealizedPnLPercent float64 `json:"unrealized_pnl_percent"`
+	FXRate               float64 `json:"fx_rate"`
+	MarketValueBase      float64 `json:"market_value_base"`
+	Weight               float64 `json:"weight"`
+}
+
+type CurrencyTotal struct {
+	Currency    string  `json:"currency"`
+	MarketValue float64 `json:"market_value"`
+	CostBasis   float64 `json:"cost_basis"`
+	Cash        float64 `json:"cash"`
+	FXRate      float64 `json:"fx_rate"`
+	Converted   bool    `json:"converted"`
+}
+
+type Valuation struct {
+	PortfolioID           int64              `json:"portfolio_id"`
+	Currency              string             `json:"currency"`
+	Holdings              []HoldingValuation `json:"holdings"`
+	MarketValue           float64            `json:"market_value"`
+	CostBasis             float64            `json:"cost_basis"`
+	UnrealizedPnL         float64            `json:"unrealized_pnl"`
+	UnrealizedPnLPercent  float64            `json:"unrealized_pnl_percent"`
+	Cash                  float64            `json:"cash"`
+	TotalValue            float64            `json:"total_value"`
+	ByCurrency            []CurrencyTotal    `json:"by_currency"`
+	UnpricedSymbols       []string           `json:"unpriced_symbols"`
+	UnconvertedCurrencies []string           `json:"unconverted_currencies"`
+}
+
+// Value combines the portfolio positions and cash with the given latest prices.
+// rates maps each currency to the amount of base currency one unit buys; the
+// base currency itself needs no entry. Holdings without a price are reported
+// but left out of the totals, as are currencies without a rate. Totals and
+// weights are in the portfolio's base currency, and cost basis is converted at
+// the same rate as market value so the P&L excludes currency moves. Every
+// currency is also summarised natively in ByCurrency.
+func Value(portfolio *store.Portfolio, prices map[string]float64, cash []store.CashBalance, rates map[string]float64) *Valuation {
+	valuation := &Valuation{
+		PortfolioID:           portfolio.ID,
+		Currency:              portfolio.BaseCurrency,
+		Holdings:              make([]HoldingValuation, 0, len(portfolio.Stocks)),
+		ByCurrency:            []CurrencyTotal{},
+		UnpricedSymbols:       []string{},
+		UnconvertedCurrencies: []string{},
+	}
+
+	rate := func(currency string) (float64, bool) {
+		if currency == valuation.Currency {
+			return 1, true
+		}
+		r, ok := rates[currency]
+		return r, ok
+	}
+
+	byCurrency := map[string]*CurrencyTotal{}
+	total := func(currency string) *CurrencyTotal {
+		if _, ok := byCurrency[currency]; !ok {
+			byCurrency[currency] = &CurrencyTotal{Currency: currency}
+		}
+		return byCurrency[currency]
+	}
+
+	for _, stock := range portfolio.Stocks {
+		holding := HoldingValuation{
+			Symbol:       stock.Symbol,
+			Currency:     stock.Currency,
+			Shares:       stock.Shares,
+			AveragePrice: stock.AveragePrice,
+			CostBasis:    stock.Shares * stock.AveragePrice,
+		}
+
+		price, ok := prices[stock.Symbol]
+		if !ok {
+			valuation.UnpricedSymbols = append(valuation.UnpricedSymbols, stock.Symbol)
+			valuation.Holdings = append(valuation.Holdings, holding)
+			continue
+		}
+
+		holding.Price = price
+		holding.Priced = true
+		holding.MarketValue = stock.Shares * price
+		holding.UnrealizedPnL = holding.MarketValue - holding.CostBasis
+		holding.UnrealizedPnLPercent = percent(holding.UnrealizedPnL, holding.CostBasis)
+
+		t := total(stock.Currency)
+		t.MarketValue += holding.MarketValue
+		t.CostBasis += holding.CostBasis
+
+		if fxRate, ok := rate(stock.Currency); ok {
+			holding.FXRate = fxRate
+			holding.MarketValueBase = holding.MarketValue * fxRate
+		}
+
+		valuation.Holdings = append(valuation.Holdings, holding)
+	}
+
+	for _, balance := range cash {
+		total(balance.Currency).Cash += balance.Balance
+	}
+
+	for _, t := range byCurrency {
+		fxRate, ok := rate(t.Currency)
+		if !ok {
+			valuation.UnconvertedCurrencies = append(valuation.UnconvertedCurrencies, t.Currency)
+			continue
+		}
+
+		t.FXRate = fxRate
+		t.Converted = true
+		valuation.MarketValue += t.MarketValue * fxRate
+		valuation.CostBasis += t.CostBasis * fxRate
+		valuation.Cash += t.Cash * fxRate
+	}
+	sort.Strings(valuation.UnconvertedCurrencies)
+
+	valuation.UnrealizedPnL = valuation.MarketValue - valuation.CostBasis
+	valuation.UnrealizedPnLPercent = percent(valuation.UnrealizedPnL, valuation.CostBasis)
+	valuation.TotalValue = valuation.MarketValue + valuation.Cash
+
+	for i := range valuation.Holdings {
+		valuation.Holdings[i].Weight = ratio(valuation.Holdings[i].MarketValueBase, valuation.MarketValue)
+	}
+
+	sort.Slice(valuation.Holdings, func(i, j int) bool {
+		return valuation.Holdings[i].MarketValueBase > valuation.Holdings[j].MarketValueBase
+	})
+
+	for _, t := range byCurrency {
+		valuation.ByCurrency = append(valuation.ByCurrency, *t)
+	}
+	sort.Slice(valuation.ByCurrency, func(i, j int) bool {
+		return valuation.ByCurrency[i].Currency < valuation.ByCurrency[j].Currency
+	})
+
+	return valuation
+}
+
+func ratio(part, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return part / total
+}
+
+func percent(part, total float64) float64 {
+	return ratio(part, total) * 100
+}
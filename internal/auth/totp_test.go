@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// The RFC 6238 appendix B SHA-1 secret "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// RFC 6238 appendix B SHA-1 vectors, truncated to the last six digits
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{unix: 59, code: "287082"},
+	{unix: 1111111109, code: "081804"},
+	{unix: 1111111111, code: "050471"},
+	{unix: 1234567890, code: "005924"},
+	{unix: 2000000000, code: "279037"},
+	{unix: 20000000000, code: "353130"},
+}
+
+func TestTOTPCode(t *testing.T) {
+	for _, tt := range rfcVectors {
+		t.Run(tt.code, func(t *testing.T) {
+			got, err := TOTPCode(rfcSecret, TOTPStep(time.Unix(tt.unix, 0)))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.code {
+				t.Errorf("got %s, want %s", got, tt.code)
+			}
+		})
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+	step := TOTPStep(at)
+
+	tests := []struct {
+		name  string
+		code  string
+		now   time.Time
+		valid bool
+	}{
+		{name: "current step", code: "050471", now: at, valid: true},
+		{name: "one step late", code: "050471", now: at.Add(TOTPPeriod * time.Second), valid: true},
+		{name: "one step early", code: "050471", now: at.Add(-TOTPPeriod * time.Second), valid: true},
+		{name: "two steps late", code: "050471", now: at.Add(2 * TOTPPeriod * time.Second), valid: false},
+		{name: "two steps early", code: "050471", now: at.Add(-2 * TOTPPeriod * time.Second), valid: false},
+		{name: "wrong code", code: "050472", now: at, valid: false},
+		{name: "wrong length", code: "50471", now: at, valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ValidateTOTP(rfcSecret, tt.code, tt.now)
+			if ok != tt.valid {
+				t.Fatalf("got valid %v, want %v", ok, tt.valid)
+			}
+			if ok && got != step {
+				t.Errorf("got step %d, want %d", got, step)
+			}
+		})
+	}
+}
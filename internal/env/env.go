@@ -33,6 +33,23 @@ func GetInt(key string, fallback int) int {
 
 	return valAsInt
 }
+func GetFloat(key string, fallback float64) float64 {
+	val, ok := os.LookupEnv(key)
+
+	if !ok {
+		return fallback
+	}
+
+	valAsFloat, err := strconv.ParseFloat(val, 64)
+
+	if err != nil {
+		log.Println(err)
+		return fallback
+	}
+
+	return valAsFloat
+}
+
 func GetDuration(key string, fallback string) time.Duration {
 	value, ok := os.LookupEnv(key)
 
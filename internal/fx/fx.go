@@ -0,0 +1,95 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+// DefaultPivot is the currency used to triangulate crosses, matching the
+// base of the ECB reference rates.
+const DefaultPivot = "EUR"
+
+var ErrRateNotFound = errors.New("exchange rate not found")
+
+// RateSource looks up the rate for a pair on date, falling back to the
+// nearest earlier date, and returns store.ErrNotFound when there is none.
+type RateSource interface {
+	GetRate(ctx context.Context, base, quote string, date time.Time) (*store.FXRate, error)
+}
+
+type Converter struct {
+	source RateSource
+	pivot  string
+}
+
+func NewConverter(source RateSource, pivot string) *Converter {
+	return &Converter{
+		source: source,
+		pivot:  pivot,
+	}
+}
+
+// Rate returns how many units of to one unit of from buys on date. It tries
+// the direct pair, its inverse, and finally a cross through the pivot currency.
+func (c *Converter) Rate(ctx context.Context, from, to string, date time.Time) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+
+	rate, err := c.pairRate(ctx, from, to, date)
+	if err == nil {
+		return rate, nil
+	}
+	if !errors.Is(err, ErrRateNotFound) {
+		return 0, err
+	}
+
+	if from == c.pivot || to == c.pivot {
+		return 0, fmt.Errorf("%w: %s/%s on %s", ErrRateNotFound, from, to, date.Format(store.DateLayout))
+	}
+
+	fromPivot, err := c.pairRate(ctx, from, c.pivot, date)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s/%s on %s", ErrRateNotFound, from, to, date.Format(store.DateLayout))
+	}
+
+	pivotTo, err := c.pairRate(ctx, c.pivot, to, date)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s/%s on %s", ErrRateNotFound, from, to, date.Format(store.DateLayout))
+	}
+
+	return fromPivot * pivotTo, nil
+}
+
+func (c *Converter) Convert(ctx context.Context, amount float64, from, to string, date time.Time) (float64, error) {
+	rate, err := c.Rate(ctx, from, to, date)
+	if err != nil {
+		return 0, err
+	}
+
+	return amount * rate, nil
+}
+
+func (c *Converter) pairRate(ctx context.Context, from, to string, date time.Time) (float64, error) {
+	direct, err := c.source.GetRate(ctx, from, to, date)
+	if err == nil {
+		return direct.Rate, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return 0, err
+	}
+
+	inverse, err := c.source.GetRate(ctx, to, from, date)
+	if err == nil {
+		return 1 / inverse.Rate, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return 0, err
+	}
+
+	return 0, ErrRateNotFound
+}
@@ -0,0 +1,65 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+func date(s string) time.Time {
+	d, _ := time.Parse(store.DateLayout, s)
+	return d
+}
+
+func TestConverterConvert(t *testing.T) {
+	source := NewMemorySource([]store.FXRate{
+		{Base: "EUR", Quote: "USD", Date: date("2024-01-02"), Rate: 1.10},
+		{Base: "EUR", Quote: "USD", Date: date("2024-01-04"), Rate: 1.20},
+		{Base: "EUR", Quote: "GBP", Date: date("2024-01-02"), Rate: 0.80},
+		{Base: "USD", Quote: "JPY", Date: date("2024-01-02"), Rate: 150},
+	})
+	converter := NewConverter(source, DefaultPivot)
+
+	tests := []struct {
+		name   string
+		amount float64
+		from   string
+		to     string
+		date   string
+		want   float64
+		err    error
+	}{
+		{name: "same currency", amount: 100, from: "USD", to: "USD", date: "2024-01-02", want: 100},
+		{name: "direct pair", amount: 100, from: "EUR", to: "USD", date: "2024-01-02", want: 110},
+		{name: "inverse pair", amount: 110, from: "USD", to: "EUR", date: "2024-01-02", want: 100},
+		{name: "nearest earlier date", amount: 100, from: "EUR", to: "USD", date: "2024-01-03", want: 110},
+		{name: "latest rate", amount: 100, from: "EUR", to: "USD", date: "2024-01-05", want: 120},
+		{name: "cross through pivot", amount: 80, from: "GBP", to: "USD", date: "2024-01-02", want: 110},
+		{name: "direct pair without pivot", amount: 1, from: "JPY", to: "USD", date: "2024-01-02", want: 1.0 / 150},
+		{name: "before first rate", amount: 100, from: "EUR", to: "USD", date: "2024-01-01", err: ErrRateNotFound},
+		{name: "unknown currency", amount: 100, from: "EUR", to: "CHF", date: "2024-01-02", err: ErrRateNotFound},
+		{name: "cross with missing leg", amount: 100, from: "GBP", to: "CHF", date: "2024-01-02", err: ErrRateNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := converter.Convert(context.Background(), tt.amount, tt.from, tt.to, date(tt.date))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got error %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
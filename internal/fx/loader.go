@@ -0,0 +1,112 @@
+package fx
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+// LoadCSV reads rates from a CSV file with a date,base,quote,rate header.
+func LoadCSV(r io.Reader) ([]store.FXRate, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"date", "base", "quote", "rate"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("fx csv: missing %q column", name)
+		}
+	}
+
+	rates := []store.FXRate{}
+	line := 1
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, err
+		}
+
+		date, err := time.Parse(store.DateLayout, record[columns["date"]])
+		if err != nil {
+			return nil, fmt.Errorf("fx csv line %d: %w", line, err)
+		}
+
+		rate, err := strconv.ParseFloat(record[columns["rate"]], 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("fx csv line %d: invalid rate %q", line, record[columns["rate"]])
+		}
+
+		rates = append(rates, store.FXRate{
+			Base:  strings.ToUpper(record[columns["base"]]),
+			Quote: strings.ToUpper(record[columns["quote"]]),
+			Date:  date,
+			Rate:  rate,
+		})
+	}
+
+	return rates, nil
+}
+
+type ecbEnvelope struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string `xml:"currency,attr"`
+			Rate     string `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+// LoadECB reads the ECB euro foreign exchange reference rates XML
+// (eurofxref-daily.xml or eurofxref-hist.xml), which are quoted against EUR.
+func LoadECB(r io.Reader) ([]store.FXRate, error) {
+	var envelope ecbEnvelope
+
+	err := xml.NewDecoder(r).Decode(&envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	rates := []store.FXRate{}
+	for _, day := range envelope.Days {
+		date, err := time.Parse(store.DateLayout, day.Time)
+		if err != nil {
+			return nil, fmt.Errorf("ecb xml: %w", err)
+		}
+
+		for _, cube := range day.Rates {
+			rate, err := strconv.ParseFloat(cube.Rate, 64)
+			if err != nil || rate <= 0 {
+				return nil, fmt.Errorf("ecb xml: invalid rate %q for %s", cube.Rate, cube.Currency)
+			}
+
+			rates = append(rates, store.FXRate{
+				Base:  "EUR",
+				Quote: cube.Currency,
+				Date:  date,
+				Rate:  rate,
+			})
+		}
+	}
+
+	return rates, nil
+}
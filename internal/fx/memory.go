@@ -0,0 +1,56 @@
+package fx
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+// MemorySource keeps rates in memory, e.g. straight from a loaded file.
+type MemorySource struct {
+	sync.RWMutex
+	rates map[string][]store.FXRate
+}
+
+func NewMemorySource(rates []store.FXRate) *MemorySource {
+	ms := &MemorySource{
+		rates: map[string][]store.FXRate{},
+	}
+	ms.Add(rates...)
+
+	return ms
+}
+
+func (ms *MemorySource) Add(rates ...store.FXRate) {
+	ms.Lock()
+	defer ms.Unlock()
+
+	for _, r := range rates {
+		key := r.Base + r.Quote
+		ms.rates[key] = append(ms.rates[key], r)
+	}
+
+	for key := range ms.rates {
+		pair := ms.rates[key]
+		sort.Slice(pair, func(i, j int) bool { return pair[i].Date.Before(pair[j].Date) })
+	}
+}
+
+func (ms *MemorySource) GetRate(ctx context.Context, base, quote string, date time.Time) (*store.FXRate, error) {
+	ms.RLock()
+	defer ms.RUnlock()
+
+	pair := ms.rates[base+quote]
+
+	// first rate after date; the one before it is the nearest prior
+	i := sort.Search(len(pair), func(i int) bool { return pair[i].Date.After(date) })
+	if i == 0 {
+		return nil, store.ErrNotFound
+	}
+
+	r := pair[i-1]
+	return &r, nil
+}
@@ -0,0 +1,268 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/marketdata"
+	"github.com/ecetinerdem/forseerv2/internal/store"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	KindTransactions = "transactions"
+	KindHoldings     = "holdings"
+)
+
+var (
+	ErrUnknownKind  = errors.New("unknown import kind, expected transactions or holdings")
+	ErrEmptyFile    = errors.New("csv file is empty")
+	ErrMissingField = errors.New("csv is missing a required column")
+)
+
+// validate checks currencies with the same iso4217 rule as the API payloads
+var validate = validator.New()
+
+var fields = map[string][]string{
+	KindTransactions: {"symbol", "type", "trade_date", "quantity", "price", "fees", "currency"},
+	KindHoldings:     {"symbol", "shares", "average_price", "trade_date", "currency"},
+}
+
+var required = map[string][]string{
+	KindTransactions: {"symbol", "type", "trade_date", "quantity"},
+	KindHoldings:     {"symbol", "shares", "average_price"},
+}
+
+type Options struct {
+	Kind       string
+	Mapping    map[string]string
+	DateLayout string
+	Today      time.Time
+}
+
+type RowResult struct {
+	Row         int                `json:"row"`
+	Valid       bool               `json:"valid"`
+	Errors      []string           `json:"errors,omitempty"`
+	Transaction *store.Transaction `json:"transaction,omitempty"`
+}
+
+type Report struct {
+	Kind        string      `json:"kind"`
+	DryRun      bool        `json:"dry_run"`
+	Committed   bool        `json:"committed"`
+	TotalRows   int         `json:"total_rows"`
+	ValidRows   int         `json:"valid_rows"`
+	InvalidRows int         `json:"invalid_rows"`
+	Errors      []string    `json:"errors,omitempty"`
+	Rows        []RowResult `json:"rows"`
+}
+
+func (r *Report) Valid() bool {
+	return r.InvalidRows == 0 && len(r.Errors) == 0
+}
+
+func (r *Report) Transactions() []*store.Transaction {
+	transactions := make([]*store.Transaction, 0, r.ValidRows)
+	for _, row := range r.Rows {
+		if row.Valid {
+			transactions = append(transactions, row.Transaction)
+		}
+	}
+	return transactions
+}
+
+// Parse reads a CSV export and turns each row into a ledger transaction.
+// Options.Mapping maps our field names to the CSV header names; unmapped
+// fields are matched against headers of the same name, case-insensitively.
+// Holdings rows become opening buys dated Options.Today unless a trade_date is given.
+func Parse(r io.Reader, opts Options) (*Report, error) {
+	kindFields, ok := fields[opts.Kind]
+	if !ok {
+		return nil, ErrUnknownKind
+	}
+	if opts.DateLayout == "" {
+		opts.DateLayout = store.DateLayout
+	}
+
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrEmptyFile
+		}
+		return nil, err
+	}
+
+	headerIndex := make(map[string]int, len(header))
+	for i, name := range header {
+		headerIndex[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+
+	columns := map[string]int{}
+	for _, field := range kindFields {
+		name := field
+		if mapped, ok := opts.Mapping[field]; ok {
+			name = mapped
+		}
+		if i, ok := headerIndex[strings.ToLower(strings.TrimSpace(name))]; ok {
+			columns[field] = i
+		}
+	}
+
+	for _, field := range required[opts.Kind] {
+		if _, ok := columns[field]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingField, field)
+		}
+	}
+
+	report := &Report{
+		Kind: opts.Kind,
+		Rows: []RowResult{},
+	}
+
+	line := 1
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, err
+		}
+
+		get := func(field string) string {
+			i, ok := columns[field]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		var result RowResult
+		if opts.Kind == KindHoldings {
+			result = parseHolding(get, opts)
+		} else {
+			result = parseTransaction(get, opts)
+		}
+		result.Row = line
+
+		report.TotalRows++
+		if result.Valid {
+			report.ValidRows++
+		} else {
+			report.InvalidRows++
+		}
+		report.Rows = append(report.Rows, result)
+	}
+
+	return report, nil
+}
+
+func parseTransaction(get func(string) string, opts Options) RowResult {
+	result := RowResult{}
+	t := &store.Transaction{
+		Symbol:   parseSymbol(get("symbol"), &result),
+		Type:     strings.ToLower(get("type")),
+		Currency: parseCurrency(get("currency"), &result),
+	}
+
+	switch t.Type {
+	case store.TransactionBuy, store.TransactionSell, store.TransactionSplit, store.TransactionDividend:
+	default:
+		result.Errors = append(result.Errors, fmt.Sprintf("unknown transaction type %q", t.Type))
+	}
+
+	t.TradeDate = parseDate(get("trade_date"), opts, &result)
+	t.Quantity = parseNumber(get("quantity"), "quantity", true, &result)
+	t.Price = parseNumber(get("price"), "price", false, &result)
+	t.Fees = parseNumber(get("fees"), "fees", false, &result)
+
+	if t.Quantity <= 0 {
+		result.Errors = append(result.Errors, "quantity must be greater than 0")
+	}
+	if t.Price < 0 || t.Fees < 0 {
+		result.Errors = append(result.Errors, "price and fees cannot be negative")
+	}
+
+	result.Valid = len(result.Errors) == 0
+	result.Transaction = t
+	return result
+}
+
+func parseHolding(get func(string) string, opts Options) RowResult {
+	result := RowResult{}
+	t := &store.Transaction{
+		Symbol:    parseSymbol(get("symbol"), &result),
+		Type:      store.TransactionBuy,
+		TradeDate: opts.Today,
+		Currency:  parseCurrency(get("currency"), &result),
+	}
+
+	if get("trade_date") != "" {
+		t.TradeDate = parseDate(get("trade_date"), opts, &result)
+	}
+	t.Quantity = parseNumber(get("shares"), "shares", true, &result)
+	t.Price = parseNumber(get("average_price"), "average_price", true, &result)
+
+	if t.Quantity <= 0 || t.Price <= 0 {
+		result.Errors = append(result.Errors, "shares and average_price must be greater than 0")
+	}
+
+	result.Valid = len(result.Errors) == 0
+	result.Transaction = t
+	return result
+}
+
+func parseDate(value string, opts Options, result *RowResult) time.Time {
+	date, err := time.Parse(opts.DateLayout, value)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("invalid date %q, expected layout %s", value, opts.DateLayout))
+	}
+	return date
+}
+
+// parseSymbol applies the same rule as the API's symbol validation.
+func parseSymbol(value string, result *RowResult) string {
+	symbol, err := marketdata.NormalizeSymbol(value)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("invalid symbol %q: %v", value, err))
+		return strings.ToUpper(strings.TrimSpace(value))
+	}
+	return symbol
+}
+
+// parseCurrency leaves the currency empty when absent so the portfolio's base currency applies.
+func parseCurrency(value string, result *RowResult) string {
+	currency := strings.ToUpper(strings.TrimSpace(value))
+	if currency != "" && validate.Var(currency, "iso4217") != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("invalid currency %q", value))
+	}
+	return currency
+}
+
+// parseNumber accepts thousands separators and currency signs common in broker exports.
+func parseNumber(value, field string, isRequired bool, result *RowResult) float64 {
+	cleaned := strings.NewReplacer(",", "", "$", "", " ", "").Replace(value)
+	if cleaned == "" {
+		if isRequired {
+			result.Errors = append(result.Errors, field+" is required")
+		}
+		return 0
+	}
+
+	n, err := strconv.ParseFloat(cleaned, 64)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("invalid %s %q", field, value))
+	}
+	return n
+}
@@ -3,9 +3,12 @@ package mailer
 import "embed"
 
 const (
-	fromName            = "ForSeer"
-	maxRetires          = 3
-	UserWelcomeTemplate = "user_invitation.tmpl"
+	fromName                    = "ForSeer"
+	maxRetires                  = 3
+	UserWelcomeTemplate         = "user_invitation.tmpl"
+	AlertTemplate               = "alert_triggered.tmpl"
+	PortfolioInvitationTemplate = "portfolio_invitation.tmpl"
+	PasswordResetTemplate       = "password_reset.tmpl"
 )
 
 //go:embed templates
@@ -0,0 +1,177 @@
+package marketdata
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+const dateLayout = "2006-01-02"
+
+// FileProvider serves prices from a directory of CSV files, one per symbol
+// (e.g. AAPL.csv) with a date,open,high,low,close,volume header.
+type FileProvider struct {
+	dir string
+}
+
+func NewFileProvider(dir string) *FileProvider {
+	return &FileProvider{
+		dir: dir,
+	}
+}
+
+func (fp *FileProvider) Quote(ctx context.Context, symbol string) (*Quote, error) {
+	history, err := fp.load(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(history.Data)
+	if n == 0 {
+		return nil, ErrNoData
+	}
+
+	last := history.Data[n-1]
+	quote := &Quote{
+		Symbol:        history.Symbol,
+		Price:         last.Close,
+		PreviousClose: last.Close,
+		Date:          last.Date,
+	}
+
+	if n > 1 {
+		quote.PreviousClose = history.Data[n-2].Close
+	}
+
+	return quote, nil
+}
+
+func (fp *FileProvider) History(ctx context.Context, symbol string, from, to time.Time) (*store.StockHistory, error) {
+	history, err := fp.load(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := &store.StockHistory{
+		Symbol: history.Symbol,
+		Data:   []store.DailyData{},
+	}
+
+	for _, d := range history.Data {
+		if d.Date.Before(from) || d.Date.After(to) {
+			continue
+		}
+		filtered.Data = append(filtered.Data, d)
+	}
+
+	return filtered, nil
+}
+
+func (fp *FileProvider) load(symbol string) (*store.StockHistory, error) {
+	symbol, err := NormalizeSymbol(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(filepath.Join(fp.dir, symbol+".csv"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrSymbolNotFound
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrNoData
+		}
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, name := range []string{"date", "open", "high", "low", "close", "volume"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%s.csv: missing %q column", symbol, name)
+		}
+	}
+
+	history := &store.StockHistory{
+		Symbol: symbol,
+		Data:   []store.DailyData{},
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		d, err := parseRecord(record, columns)
+		if err != nil {
+			return nil, fmt.Errorf("%s.csv: %w", symbol, err)
+		}
+		history.Data = append(history.Data, d)
+	}
+
+	sort.Slice(history.Data, func(i, j int) bool {
+		return history.Data[i].Date.Before(history.Data[j].Date)
+	})
+
+	return history, nil
+}
+
+func parseRecord(record []string, columns map[string]int) (store.DailyData, error) {
+	var d store.DailyData
+	var err error
+
+	d.Date, err = time.Parse(dateLayout, record[columns["date"]])
+	if err != nil {
+		return d, err
+	}
+
+	prices := []struct {
+		column string
+		dst    *float64
+	}{
+		{"open", &d.Open},
+		{"high", &d.High},
+		{"low", &d.Low},
+		{"close", &d.Close},
+	}
+
+	for _, p := range prices {
+		*p.dst, err = strconv.ParseFloat(record[columns[p.column]], 64)
+		if err != nil {
+			return d, err
+		}
+	}
+
+	d.Volume, err = strconv.ParseInt(record[columns["volume"]], 10, 64)
+	if err != nil {
+		return d, err
+	}
+
+	return d, nil
+}
@@ -0,0 +1,146 @@
+package marketdata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+// HTTPProvider talks to a remote JSON price API exposing
+// /quote/{symbol} and /history/{symbol}?from=&to= endpoints.
+type HTTPProvider struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+type quoteResponse struct {
+	Symbol        string  `json:"symbol"`
+	Price         float64 `json:"price"`
+	PreviousClose float64 `json:"previous_close"`
+	Date          string  `json:"date"`
+}
+
+type historyResponse struct {
+	Symbol string `json:"symbol"`
+	Data   []struct {
+		Date   string  `json:"date"`
+		Open   float64 `json:"open"`
+		High   float64 `json:"high"`
+		Low    float64 `json:"low"`
+		Close  float64 `json:"close"`
+		Volume int64   `json:"volume"`
+	} `json:"data"`
+}
+
+func NewHTTPProvider(baseURL, apiKey string) *HTTPProvider {
+	return &HTTPProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		client: &http.Client{
+			Timeout: time.Second * 10,
+		},
+	}
+}
+
+func (hp *HTTPProvider) Quote(ctx context.Context, symbol string) (*Quote, error) {
+	symbol, err := NormalizeSymbol(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp quoteResponse
+	err = hp.get(ctx, "/quote/"+url.PathEscape(symbol), url.Values{}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	date, err := time.Parse(dateLayout, resp.Date)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Quote{
+		Symbol:        symbol,
+		Price:         resp.Price,
+		PreviousClose: resp.PreviousClose,
+		Date:          date,
+	}, nil
+}
+
+func (hp *HTTPProvider) History(ctx context.Context, symbol string, from, to time.Time) (*store.StockHistory, error) {
+	symbol, err := NormalizeSymbol(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("from", from.Format(dateLayout))
+	params.Set("to", to.Format(dateLayout))
+
+	var resp historyResponse
+	err = hp.get(ctx, "/history/"+url.PathEscape(symbol), params, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &store.StockHistory{
+		Symbol: symbol,
+		Data:   make([]store.DailyData, 0, len(resp.Data)),
+	}
+
+	for _, d := range resp.Data {
+		date, err := time.Parse(dateLayout, d.Date)
+		if err != nil {
+			return nil, err
+		}
+		history.Data = append(history.Data, store.DailyData{
+			Date:   date,
+			Open:   d.Open,
+			High:   d.High,
+			Low:    d.Low,
+			Close:  d.Close,
+			Volume: d.Volume,
+		})
+	}
+
+	return history, nil
+}
+
+func (hp *HTTPProvider) get(ctx context.Context, path string, params url.Values, dst any) error {
+	if hp.apiKey != "" {
+		params.Set("apikey", hp.apiKey)
+	}
+
+	endpoint := hp.baseURL + path
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := hp.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return ErrSymbolNotFound
+	case res.StatusCode != http.StatusOK:
+		return fmt.Errorf("market data provider returned status %d", res.StatusCode)
+	}
+
+	return json.NewDecoder(res.Body).Decode(dst)
+}
@@ -0,0 +1,41 @@
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+var (
+	ErrSymbolNotFound = errors.New("symbol not found")
+	ErrInvalidSymbol  = errors.New("invalid symbol")
+	ErrNoData         = errors.New("no market data available")
+
+	symbolPattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9.\-]{0,19}$`)
+)
+
+type Quote struct {
+	Symbol        string    `json:"symbol"`
+	Price         float64   `json:"price"`
+	PreviousClose float64   `json:"previous_close"`
+	Date          time.Time `json:"date"`
+}
+
+type Provider interface {
+	Quote(ctx context.Context, symbol string) (*Quote, error)
+	History(ctx context.Context, symbol string, from, to time.Time) (*store.StockHistory, error)
+}
+
+func NormalizeSymbol(symbol string) (string, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+
+	if !symbolPattern.MatchString(symbol) {
+		return "", ErrInvalidSymbol
+	}
+
+	return symbol, nil
+}
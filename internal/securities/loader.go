@@ -0,0 +1,100 @@
+package securities
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ecetinerdem/forseerv2/internal/marketdata"
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+var requiredColumns = []string{"symbol", "name"}
+
+// Load reads securities from a CSV file with a header row. symbol and name
+// are required; exchange, asset_class, currency, sector and country (ISO
+// 3166 alpha-2) are optional and currency defaults to store.DefaultCurrency.
+func Load(r io.Reader) ([]store.Security, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.TrimPrefix(name, "\ufeff")
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range requiredColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("securities csv: missing %q column", name)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	securities := []store.Security{}
+	seen := map[string]bool{}
+	line := 1
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, err
+		}
+
+		symbol, err := marketdata.NormalizeSymbol(field(record, "symbol"))
+		if err != nil {
+			return nil, fmt.Errorf("securities csv line %d: %w", line, err)
+		}
+		if seen[symbol] {
+			return nil, fmt.Errorf("securities csv line %d: duplicate symbol %s", line, symbol)
+		}
+		seen[symbol] = true
+
+		name := field(record, "name")
+		if name == "" {
+			return nil, fmt.Errorf("securities csv line %d: missing name", line)
+		}
+
+		currency := strings.ToUpper(field(record, "currency"))
+		if currency == "" {
+			currency = store.DefaultCurrency
+		}
+		if len(currency) != 3 {
+			return nil, fmt.Errorf("securities csv line %d: invalid currency %q", line, currency)
+		}
+
+		country := strings.ToUpper(field(record, "country"))
+		if country != "" && len(country) != 2 {
+			return nil, fmt.Errorf("securities csv line %d: invalid country %q", line, country)
+		}
+
+		securities = append(securities, store.Security{
+			Symbol:     symbol,
+			Name:       name,
+			Exchange:   strings.ToUpper(field(record, "exchange")),
+			AssetClass: strings.ToLower(field(record, "asset_class")),
+			Currency:   currency,
+			Sector:     field(record, "sector"),
+			Country:    country,
+		})
+	}
+
+	return securities, nil
+}
@@ -0,0 +1,307 @@
+package snapshots
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/analysis"
+	"github.com/ecetinerdem/forseerv2/internal/fx"
+	"github.com/ecetinerdem/forseerv2/internal/marketdata"
+	"github.com/ecetinerdem/forseerv2/internal/store"
+	"go.uber.org/zap"
+)
+
+// Prices are looked up this many days before the first snapshot so that a
+// holding is priced even when the range starts on a market holiday.
+const priceLookback = 10
+
+type Config struct {
+	Interval time.Duration
+	Lookback int
+}
+
+// Recorder values portfolios at past end of day prices and stores the result
+// as daily snapshots.
+type Recorder struct {
+	store      *store.Storage
+	marketData marketdata.Provider
+	fx         *fx.Converter
+	logger     *zap.SugaredLogger
+	config     Config
+}
+
+func NewRecorder(store *store.Storage, marketData marketdata.Provider, fx *fx.Converter, logger *zap.SugaredLogger, config Config) *Recorder {
+	return &Recorder{
+		store:      store,
+		marketData: marketData,
+		fx:         fx,
+		logger:     logger,
+		config:     config,
+	}
+}
+
+// Run snapshots every portfolio each interval until ctx is cancelled. Each
+// pass also retakes the last Lookback days so late prices are picked up.
+func (r *Recorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	r.logger.Infow("snapshot recorder started", "interval", r.config.Interval.String())
+
+	for {
+		to := time.Now().UTC().Truncate(24 * time.Hour)
+		from := to.AddDate(0, 0, -r.config.Lookback)
+
+		_, err := r.RecordAll(ctx, from, to)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			r.logger.Errorw("snapshot recording failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			r.logger.Info("snapshot recorder stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RecordAll snapshots every portfolio for each weekday between from and to
+// and returns the number of snapshots stored. A portfolio that fails is
+// logged and skipped.
+func (r *Recorder) RecordAll(ctx context.Context, from, to time.Time) (int, error) {
+	portfolios, err := r.store.Portfolio.GetAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	histories := map[string]*store.StockHistory{}
+	recorded := 0
+
+	for _, portfolio := range portfolios {
+		if ctx.Err() != nil {
+			return recorded, ctx.Err()
+		}
+
+		n, err := r.record(ctx, portfolio, from, to, histories)
+		if err != nil {
+			r.logger.Warnw("portfolio snapshot skipped", "portfolio", portfolio.ID, "error", err)
+			continue
+		}
+		recorded += n
+	}
+
+	return recorded, nil
+}
+
+func (r *Recorder) record(ctx context.Context, portfolio *store.Portfolio, from, to time.Time, histories map[string]*store.StockHistory) (int, error) {
+	transactions, err := r.store.Transactions.GetByPortfolio(ctx, portfolio.ID, "")
+	if err != nil {
+		return 0, err
+	}
+
+	entries, err := r.store.Cash.GetEntries(ctx, portfolio.ID, "")
+	if err != nil {
+		return 0, err
+	}
+
+	// Nothing to value before the first transaction or cash entry
+	var start time.Time
+	if len(transactions) > 0 {
+		start = transactions[0].TradeDate
+	}
+	if len(entries) > 0 && (start.IsZero() || entries[0].EntryDate.Before(start)) {
+		start = entries[0].EntryDate
+	}
+	if start.IsZero() {
+		return 0, nil
+	}
+
+	start = start.UTC().Truncate(24 * time.Hour)
+	if from.After(start) {
+		start = from
+	}
+	if start.After(to) {
+		return 0, nil
+	}
+
+	prices := map[string]*priceCursor{}
+	for _, t := range transactions {
+		if _, ok := prices[t.Symbol]; ok {
+			continue
+		}
+
+		history, err := r.history(ctx, t.Symbol, from.AddDate(0, 0, -priceLookback), to, histories)
+		if err != nil {
+			return 0, err
+		}
+		prices[t.Symbol] = &priceCursor{data: history.Data}
+	}
+
+	rates := map[string]float64{}
+	snapshots := []store.Snapshot{}
+
+	// The ledger and cash entries come sorted by date, so both are applied in
+	// one pass as the days advance.
+	book := store.NewLotBook(portfolio.CostBasisMethod)
+	cash := &cashCursor{entries: entries, balances: map[string]float64{}}
+	next := 0
+
+	for _, date := range weekdays(start, to) {
+		end := date.AddDate(0, 0, 1)
+		for ; next < len(transactions) && transactions[next].TradeDate.Before(end); next++ {
+			_, err := book.Apply(transactions[next])
+			if err != nil {
+				return 0, err
+			}
+		}
+		stocks := book.Positions()
+
+		held := *portfolio
+		held.Stocks = stocks
+
+		closes := make(map[string]float64, len(stocks))
+		currencies := map[string]bool{}
+		for _, stock := range stocks {
+			if price, ok := prices[stock.Symbol].at(date); ok {
+				closes[stock.Symbol] = price
+			}
+			currencies[stock.Currency] = true
+		}
+
+		balances := cash.at(end)
+		for _, balance := range balances {
+			currencies[balance.Currency] = true
+		}
+
+		for currency := range currencies {
+			if currency == portfolio.BaseCurrency {
+				continue
+			}
+
+			rate, err := r.fx.Rate(ctx, currency, portfolio.BaseCurrency, date)
+			if err != nil {
+				if errors.Is(err, fx.ErrRateNotFound) {
+					delete(rates, currency)
+					continue
+				}
+				return 0, err
+			}
+			rates[currency] = rate
+		}
+
+		valuation := analysis.Value(&held, closes, balances, rates)
+
+		snapshots = append(snapshots, store.Snapshot{
+			PortfolioID:           portfolio.ID,
+			Date:                  date,
+			Currency:              valuation.Currency,
+			MarketValue:           valuation.MarketValue,
+			CostBasis:             valuation.CostBasis,
+			Cash:                  valuation.Cash,
+			TotalValue:            valuation.TotalValue,
+			UnpricedSymbols:       valuation.UnpricedSymbols,
+			UnconvertedCurrencies: valuation.UnconvertedCurrencies,
+		})
+	}
+
+	err = r.store.Snapshots.Upsert(ctx, snapshots)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(snapshots), nil
+}
+
+// Ask market data provider or fall back to the stored closes. Histories are
+// shared across portfolios within one pass.
+func (r *Recorder) history(ctx context.Context, symbol string, from, to time.Time, histories map[string]*store.StockHistory) (*store.StockHistory, error) {
+	if history, ok := histories[symbol]; ok {
+		return history, nil
+	}
+
+	history, err := r.marketData.History(ctx, symbol, from, to)
+	if err == nil {
+		err = r.store.Stocks.UpsertHistory(ctx, history)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		r.logger.Warnw("market data history failed, using stored closes", "symbol", symbol, "error", err)
+
+		history, err = r.store.Stocks.GetHistory(ctx, symbol, from, to)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(history.Data, func(i, j int) bool {
+		return history.Data[i].Date.Before(history.Data[j].Date)
+	})
+	histories[symbol] = history
+
+	return history, nil
+}
+
+// cashCursor keeps running balances per currency over date sorted entries.
+type cashCursor struct {
+	entries    []store.CashEntry
+	i          int
+	balances   map[string]float64
+	currencies []string
+}
+
+// at returns the balances of the entries dated before end. end must not
+// decrease between calls.
+func (c *cashCursor) at(end time.Time) []store.CashBalance {
+	for ; c.i < len(c.entries) && c.entries[c.i].EntryDate.Before(end); c.i++ {
+		e := c.entries[c.i]
+		if _, ok := c.balances[e.Currency]; !ok {
+			c.currencies = append(c.currencies, e.Currency)
+		}
+		c.balances[e.Currency] += e.Amount
+	}
+
+	balances := make([]store.CashBalance, 0, len(c.currencies))
+	for _, currency := range c.currencies {
+		balances = append(balances, store.CashBalance{Currency: currency, Balance: c.balances[currency]})
+	}
+
+	return balances
+}
+
+func weekdays(from, to time.Time) []time.Time {
+	dates := []time.Time{}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+			continue
+		}
+		dates = append(dates, d)
+	}
+	return dates
+}
+
+// priceCursor walks a sorted history forward, returning the last close on or
+// before each requested date. Dates must be requested in ascending order.
+type priceCursor struct {
+	data  []store.DailyData
+	i     int
+	close float64
+	found bool
+}
+
+func (c *priceCursor) at(date time.Time) (float64, bool) {
+	if c == nil {
+		return 0, false
+	}
+
+	for c.i < len(c.data) && !c.data[c.i].Date.After(date) {
+		c.close = c.data[c.i].Close
+		c.found = true
+		c.i++
+	}
+
+	return c.close, c.found
+}
@@ -0,0 +1,48 @@
+package snapshots
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ecetinerdem/forseerv2/internal/store"
+)
+
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+var ErrInvalidInterval = errors.New("invalid interval, expected one of day, week, month")
+
+// Resample keeps the last snapshot of each week or month. Snapshots must be
+// sorted by date.
+func Resample(snapshots []store.Snapshot, interval string) ([]store.Snapshot, error) {
+	var bucket func(time.Time) int
+
+	switch interval {
+	case "", IntervalDay:
+		return snapshots, nil
+	case IntervalWeek:
+		bucket = func(t time.Time) int {
+			year, week := t.ISOWeek()
+			return year*100 + week
+		}
+	case IntervalMonth:
+		bucket = func(t time.Time) int {
+			return t.Year()*100 + int(t.Month())
+		}
+	default:
+		return nil, ErrInvalidInterval
+	}
+
+	resampled := []store.Snapshot{}
+	for i, s := range snapshots {
+		if i+1 < len(snapshots) && bucket(snapshots[i+1].Date) == bucket(s.Date) {
+			continue
+		}
+		resampled = append(resampled, s)
+	}
+
+	return resampled, nil
+}
@@ -0,0 +1,245 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	AlertPrice           = "price"
+	AlertPortfolioChange = "portfolio_change"
+
+	AlertAbove = "above"
+	AlertBelow = "below"
+
+	AlertArmed     = "armed"
+	AlertTriggered = "triggered"
+)
+
+// Alert watches a symbol's price or a portfolio's daily change in percent.
+// It fires when armed and its condition is met, then stays triggered until
+// the condition clears and it re-arms, so it fires once per crossing.
+type Alert struct {
+	ID              int64      `json:"id"`
+	UserID          int64      `json:"user_id"`
+	Kind            string     `json:"kind"`
+	Symbol          string     `json:"symbol"`
+	PortfolioID     *int64     `json:"portfolio_id"`
+	Condition       string     `json:"condition"`
+	Threshold       float64    `json:"threshold"`
+	State           string     `json:"state"`
+	Active          bool       `json:"active"`
+	LastValue       *float64   `json:"last_value"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at"`
+	CreatedAt       string     `json:"created_at"`
+	UpdatedAt       string     `json:"updated_at"`
+}
+
+// Met reports whether value satisfies the alert's condition.
+func (a *Alert) Met(value float64) bool {
+	switch a.Condition {
+	case AlertAbove:
+		return value >= a.Threshold
+	case AlertBelow:
+		return value <= a.Threshold
+	}
+	return false
+}
+
+func (a *Alert) Describe() string {
+	switch a.Kind {
+	case AlertPortfolioChange:
+		return fmt.Sprintf("portfolio daily change %s %g%%", a.Condition, a.Threshold)
+	default:
+		return fmt.Sprintf("%s %s %g", a.Symbol, a.Condition, a.Threshold)
+	}
+}
+
+type AlertStore struct {
+	db *sql.DB
+}
+
+const alertColumns = `id, user_id, kind, symbol, portfolio_id, condition, threshold, state, active, last_value, last_triggered_at, created_at, updated_at`
+
+func (as *AlertStore) Create(ctx context.Context, alert *Alert) error {
+	query := `
+		INSERT INTO alerts (user_id, kind, symbol, portfolio_id, condition, threshold)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + alertColumns
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	row := as.db.QueryRowContext(ctx, query, alert.UserID, alert.Kind, alert.Symbol, alert.PortfolioID, alert.Condition, alert.Threshold)
+
+	return scanAlert(row, alert)
+}
+
+func (as *AlertStore) GetByUser(ctx context.Context, userID int64) ([]Alert, error) {
+	query := `SELECT ` + alertColumns + ` FROM alerts WHERE user_id = $1 ORDER BY created_at DESC, id DESC`
+
+	return as.query(ctx, query, userID)
+}
+
+func (as *AlertStore) GetByID(ctx context.Context, alertID int64, userID int64) (*Alert, error) {
+	query := `SELECT ` + alertColumns + ` FROM alerts WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	var alert Alert
+	err := scanAlert(as.db.QueryRowContext(ctx, query, alertID, userID), &alert)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &alert, nil
+}
+
+// GetActive returns every active alert for the evaluator.
+func (as *AlertStore) GetActive(ctx context.Context) ([]Alert, error) {
+	query := `SELECT ` + alertColumns + ` FROM alerts WHERE active ORDER BY id`
+
+	return as.query(ctx, query)
+}
+
+// Update saves the condition, threshold and active flag. The alert re-arms
+// since its previous crossing no longer applies.
+func (as *AlertStore) Update(ctx context.Context, alert *Alert) error {
+	query := `
+		UPDATE alerts
+		SET condition = $1, threshold = $2, active = $3, state = 'armed', updated_at = NOW()
+		WHERE id = $4 AND user_id = $5
+		RETURNING ` + alertColumns
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	err := scanAlert(as.db.QueryRowContext(ctx, query, alert.Condition, alert.Threshold, alert.Active, alert.ID, alert.UserID), alert)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (as *AlertStore) Delete(ctx context.Context, alertID int64, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	res, err := as.db.ExecContext(ctx, `DELETE FROM alerts WHERE id = $1 AND user_id = $2`, alertID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Trigger moves an armed alert to triggered. It reports false when the alert
+// was not armed, so concurrent evaluators notify only once.
+func (as *AlertStore) Trigger(ctx context.Context, alertID int64, value float64) (bool, error) {
+	query := `
+		UPDATE alerts
+		SET state = 'triggered', last_value = $1, last_triggered_at = NOW(), updated_at = NOW()
+		WHERE id = $2 AND state = 'armed' AND active
+	`
+
+	return as.transition(ctx, query, value, alertID)
+}
+
+// Rearm moves a triggered alert back to armed once its condition has cleared.
+func (as *AlertStore) Rearm(ctx context.Context, alertID int64, value float64) (bool, error) {
+	query := `
+		UPDATE alerts
+		SET state = 'armed', last_value = $1, updated_at = NOW()
+		WHERE id = $2 AND state = 'triggered'
+	`
+
+	return as.transition(ctx, query, value, alertID)
+}
+
+func (as *AlertStore) transition(ctx context.Context, query string, value float64, alertID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	res, err := as.db.ExecContext(ctx, query, value, alertID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+func (as *AlertStore) query(ctx context.Context, query string, args ...any) ([]Alert, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	rows, err := as.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []Alert{}
+	for rows.Next() {
+		var alert Alert
+		if err := scanAlert(rows, &alert); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return alerts, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAlert(row rowScanner, alert *Alert) error {
+	return row.Scan(
+		&alert.ID,
+		&alert.UserID,
+		&alert.Kind,
+		&alert.Symbol,
+		&alert.PortfolioID,
+		&alert.Condition,
+		&alert.Threshold,
+		&alert.State,
+		&alert.Active,
+		&alert.LastValue,
+		&alert.LastTriggeredAt,
+		&alert.CreatedAt,
+		&alert.UpdatedAt,
+	)
+}
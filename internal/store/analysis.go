@@ -3,20 +3,61 @@ package store
 import "time"
 
 type StockHistory struct {
-	Symbol string
-	Data   []DailyData
+	Symbol string      `json:"symbol"`
+	Data   []DailyData `json:"data"`
 }
 
 type DailyData struct {
-	Date   time.Time
-	Open   float64
-	High   float64
-	Low    float64
-	Close  float64
-	Volume int64
+	Date   time.Time `json:"date"`
+	Open   float64   `json:"open"`
+	High   float64   `json:"high"`
+	Low    float64   `json:"low"`
+	Close  float64   `json:"close"`
+	Volume int64     `json:"volume"`
 }
 
 type PortfolioAnalysis struct {
-	PortfolioID int
-	Stocks      []StockHistory
+	PortfolioID           int64                 `json:"portfolio_id"`
+	Period                string                `json:"period"`
+	Currency              string                `json:"currency"`
+	From                  time.Time             `json:"from"`
+	To                    time.Time             `json:"to"`
+	Stocks                []StockHistory        `json:"-"`
+	StartValue            float64               `json:"start_value"`
+	EndValue              float64               `json:"end_value"`
+	TimeWeightedReturn    float64               `json:"time_weighted_return"`
+	AnnualizedReturn      float64               `json:"annualized_return"`
+	AnnualizedVolatility  float64               `json:"annualized_volatility"`
+	MaxDrawdown           float64               `json:"max_drawdown"`
+	SharpeRatio           float64               `json:"sharpe_ratio"`
+	SortinoRatio          float64               `json:"sortino_ratio"`
+	Contributions         []HoldingContribution `json:"contributions"`
+	Benchmark             *BenchmarkComparison  `json:"benchmark,omitempty"`
+	UnpricedSymbols       []string              `json:"unpriced_symbols"`
+	UnconvertedCurrencies []string              `json:"unconverted_currencies"`
+}
+
+type HoldingContribution struct {
+	Symbol       string  `json:"symbol"`
+	StartWeight  float64 `json:"start_weight"`
+	Return       float64 `json:"return"`
+	Contribution float64 `json:"contribution"`
+}
+
+type BenchmarkComparison struct {
+	Symbol           string            `json:"symbol"`
+	Return           float64           `json:"return"`
+	AnnualizedReturn float64           `json:"annualized_return"`
+	ExcessReturn     float64           `json:"excess_return"`
+	Alpha            float64           `json:"alpha"`
+	Beta             float64           `json:"beta"`
+	TrackingError    float64           `json:"tracking_error"`
+	InformationRatio float64           `json:"information_ratio"`
+	Series           []CumulativePoint `json:"series"`
+}
+
+type CumulativePoint struct {
+	Date      time.Time `json:"date"`
+	Portfolio float64   `json:"portfolio"`
+	Benchmark float64   `json:"benchmark"`
 }
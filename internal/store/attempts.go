@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// AttemptStore counts failed attempts against a key, such as a challenge or a
+// user, until the count expires. It is used when Redis is not enabled.
+type AttemptStore struct {
+	db *sql.DB
+}
+
+// Increment records one more failed attempt and returns the count so far.
+// Each attempt pushes the expiry out, and an expired count starts over.
+func (as *AttemptStore) Increment(ctx context.Context, id string, expiry time.Time) (int64, error) {
+	query := `
+		INSERT INTO challenge_attempts (id, attempts, expiry)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (id) DO UPDATE
+		SET attempts = CASE WHEN challenge_attempts.expiry < NOW() THEN 1 ELSE challenge_attempts.attempts + 1 END,
+			expiry = EXCLUDED.expiry
+		RETURNING attempts
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	var attempts int64
+	err := as.db.QueryRowContext(ctx, query, id, expiry).Scan(&attempts)
+	if err != nil {
+		return 0, err
+	}
+
+	// Counts are useless once their challenge has expired
+	_, err = as.db.ExecContext(ctx, `DELETE FROM challenge_attempts WHERE expiry < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+
+	return attempts, nil
+}
+
+// Get returns the failed attempts counted against id that have not expired.
+func (as *AttemptStore) Get(ctx context.Context, id string) (int64, error) {
+	query := `SELECT COALESCE((SELECT attempts FROM challenge_attempts WHERE id = $1 AND expiry > NOW()), 0)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	var attempts int64
+	err := as.db.QueryRowContext(ctx, query, id).Scan(&attempts)
+	if err != nil {
+		return 0, err
+	}
+
+	return attempts, nil
+}
+
+func (as *AttemptStore) Reset(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	_, err := as.db.ExecContext(ctx, `DELETE FROM challenge_attempts WHERE id = $1`, id)
+	return err
+}
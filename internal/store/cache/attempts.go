@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type AttemptStore struct {
+	rdb *redis.Client
+}
+
+// Increment records one more failed attempt and returns the count so far. The
+// counter lives until expiry, which each attempt pushes out.
+func (as *AttemptStore) Increment(ctx context.Context, id string, expiry time.Time) (int64, error) {
+	cacheKey := fmt.Sprintf("attempts-%v", id)
+
+	pipe := as.rdb.TxPipeline()
+	incr := pipe.Incr(ctx, cacheKey)
+	pipe.ExpireAt(ctx, cacheKey, expiry)
+
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return incr.Val(), nil
+}
+
+func (as *AttemptStore) Get(ctx context.Context, id string) (int64, error) {
+	cacheKey := fmt.Sprintf("attempts-%v", id)
+
+	attempts, err := as.rdb.Get(ctx, cacheKey).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return attempts, nil
+}
+
+func (as *AttemptStore) Reset(ctx context.Context, id string) error {
+	cacheKey := fmt.Sprintf("attempts-%v", id)
+
+	return as.rdb.Del(ctx, cacheKey).Err()
+}
@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type RevocationStore struct {
+	rdb *redis.Client
+}
+
+// Revoke keeps the ID until expiry, after which its tokens are expired anyway.
+func (rs *RevocationStore) Revoke(ctx context.Context, id string, expiry time.Time) error {
+	cacheKey := fmt.Sprintf("revoked-%v", id)
+
+	ttl := time.Until(expiry)
+	if ttl <= 0 {
+		return nil
+	}
+
+	return rs.rdb.SetEx(ctx, cacheKey, "1", ttl).Err()
+}
+
+func (rs *RevocationStore) IsRevoked(ctx context.Context, id string) (bool, error) {
+	cacheKey := fmt.Sprintf("revoked-%v", id)
+
+	n, err := rs.rdb.Exists(ctx, cacheKey).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
@@ -17,16 +17,28 @@ type Storage struct {
 	Users interface {
 		Get(context.Context, int64) (*store.User, error)
 		Set(context.Context, *store.User) error
+		Delete(context.Context, int64) error
 	}
 	Portfolio interface {
 		Get(context.Context, int64) (*store.Portfolio, error)
 		Set(context.Context, *store.Portfolio) error
 	}
+	Revocations interface {
+		Revoke(context.Context, string, time.Time) error
+		IsRevoked(context.Context, string) (bool, error)
+	}
+	Attempts interface {
+		Increment(context.Context, string, time.Time) (int64, error)
+		Get(context.Context, string) (int64, error)
+		Reset(context.Context, string) error
+	}
 }
 
 func NewRedisStorage(rbd *redis.Client) Storage {
 	return Storage{
-		Users:     &UserStore{rdb: rbd},
-		Portfolio: &PortfolioStore{rdb: rbd},
+		Users:       &UserStore{rdb: rbd},
+		Portfolio:   &PortfolioStore{rdb: rbd},
+		Revocations: &RevocationStore{rdb: rbd},
+		Attempts:    &AttemptStore{rdb: rbd},
 	}
 }
@@ -49,3 +49,9 @@ func (us *UserStore) Set(ctx context.Context, user *store.User) error {
 	}
 	return nil
 }
+
+func (us *UserStore) Delete(ctx context.Context, userID int64) error {
+	cacheKey := fmt.Sprintf("user-%v", userID)
+
+	return us.rdb.Del(ctx, cacheKey).Err()
+}
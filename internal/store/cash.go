@@ -0,0 +1,162 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	DefaultCurrency = "USD"
+
+	CashDeposit    = "deposit"
+	CashWithdrawal = "withdrawal"
+	CashDividend   = "dividend"
+	CashInterest   = "interest"
+	CashFee        = "fee"
+)
+
+type CashEntry struct {
+	ID          int64     `json:"id"`
+	PortfolioID int64     `json:"portfolio_id"`
+	Currency    string    `json:"currency"`
+	Type        string    `json:"type"`
+	Amount      float64   `json:"amount"`
+	EntryDate   time.Time `json:"entry_date"`
+	Description string    `json:"description"`
+	CreatedAt   string    `json:"created_at"`
+}
+
+type CashBalance struct {
+	Currency string  `json:"currency"`
+	Balance  float64 `json:"balance"`
+}
+
+type CashStore struct {
+	db *sql.DB
+}
+
+// Record appends an entry to the cash ledger. Amount is signed: withdrawals
+// and fees are stored as negative amounts.
+func (cs *CashStore) Record(ctx context.Context, portfolioID int64, userID int64, entry *CashEntry) error {
+	return withTX(cs.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+		defer cancel()
+
+		exist, err := checkPortfolioExist(ctx, tx, portfolioID, userID)
+		if err != nil {
+			return err
+		}
+		if !exist {
+			return ErrNotFound
+		}
+
+		entry.PortfolioID = portfolioID
+		err = insertCashEntry(ctx, tx, entry)
+		if err != nil {
+			return err
+		}
+
+		return touchPortfolio(ctx, tx, portfolioID)
+	})
+}
+
+func (cs *CashStore) GetEntries(ctx context.Context, portfolioID int64, currency string) ([]CashEntry, error) {
+	query := `
+		SELECT id, portfolio_id, currency, type, amount, entry_date, description, created_at
+		FROM portfolio_cash
+		WHERE portfolio_id = $1 AND ($2 = '' OR currency = $2)
+		ORDER BY entry_date ASC, id ASC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	rows, err := cs.db.QueryContext(ctx, query, portfolioID, currency)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []CashEntry{}
+	for rows.Next() {
+		var e CashEntry
+
+		err := rows.Scan(
+			&e.ID,
+			&e.PortfolioID,
+			&e.Currency,
+			&e.Type,
+			&e.Amount,
+			&e.EntryDate,
+			&e.Description,
+			&e.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (cs *CashStore) GetBalances(ctx context.Context, portfolioID int64) ([]CashBalance, error) {
+	query := `
+		SELECT currency, SUM(amount)
+		FROM portfolio_cash
+		WHERE portfolio_id = $1
+		GROUP BY currency
+		ORDER BY currency ASC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	rows, err := cs.db.QueryContext(ctx, query, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := []CashBalance{}
+	for rows.Next() {
+		var b CashBalance
+		if err := rows.Scan(&b.Currency, &b.Balance); err != nil {
+			return nil, err
+		}
+		balances = append(balances, b)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return balances, nil
+}
+
+func insertCashEntry(ctx context.Context, tx *sql.Tx, entry *CashEntry) error {
+	query := `
+		INSERT INTO portfolio_cash (portfolio_id, currency, type, amount, entry_date, description)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		entry.PortfolioID,
+		entry.Currency,
+		entry.Type,
+		entry.Amount,
+		entry.EntryDate.Format(DateLayout),
+		entry.Description,
+	).Scan(
+		&entry.ID,
+		&entry.CreatedAt,
+	)
+}
@@ -0,0 +1,350 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	ActionDividend     = "dividend"
+	ActionSplit        = "split"
+	ActionTickerChange = "ticker_change"
+)
+
+type CorporateAction struct {
+	ID        int64           `json:"id"`
+	Symbol    string          `json:"symbol"`
+	Type      string          `json:"type"`
+	ExDate    time.Time       `json:"ex_date"`
+	PayDate   time.Time       `json:"pay_date"`
+	Amount    float64         `json:"amount"`
+	Ratio     float64         `json:"ratio"`
+	NewSymbol string          `json:"new_symbol"`
+	Currency  string          `json:"currency"`
+	AppliedTo int             `json:"applied_to"`
+	Failures  []ActionFailure `json:"failures,omitempty"`
+	CreatedAt string          `json:"created_at"`
+}
+
+// ActionFailure is a portfolio a corporate action could not be applied to.
+type ActionFailure struct {
+	PortfolioID int64  `json:"portfolio_id"`
+	Error       string `json:"error"`
+}
+
+type CorporateActionStore struct {
+	db *sql.DB
+}
+
+// Create records a corporate action and applies it to every portfolio that
+// held the symbol before the ex-date. Each portfolio is adjusted in its own
+// transaction, so one that fails is left as it was and listed in Failures
+// without holding back the others. AppliedTo is set to the number of
+// portfolios adjusted.
+func (cs *CorporateActionStore) Create(ctx context.Context, action *CorporateAction) error {
+	var portfolioIDs []int64
+
+	err := withTX(cs.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+		defer cancel()
+
+		query := `
+			INSERT INTO corporate_actions (symbol, type, ex_date, pay_date, amount, ratio, new_symbol, currency)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id, created_at
+		`
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			action.Symbol,
+			action.Type,
+			action.ExDate.Format(DateLayout),
+			action.PayDate.Format(DateLayout),
+			action.Amount,
+			action.Ratio,
+			action.NewSymbol,
+			action.Currency,
+		).Scan(
+			&action.ID,
+			&action.CreatedAt,
+		)
+		if err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Constraint == "corporate_actions_symbol_type_ex_date_key" {
+				return ErrDuplicateAction
+			}
+			return err
+		}
+
+		if action.Type == ActionTickerChange {
+			err = renameSymbolReferences(ctx, tx, action.Symbol, action.NewSymbol)
+			if err != nil {
+				return err
+			}
+		}
+
+		portfolioIDs, err = getActionPortfolios(ctx, tx, action)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, portfolioID := range portfolioIDs {
+		var applied bool
+
+		err := withTX(cs.db, ctx, func(tx *sql.Tx) error {
+			ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+			defer cancel()
+
+			var err error
+			applied, err = applyToPortfolio(ctx, tx, portfolioID, action)
+			return err
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			action.Failures = append(action.Failures, ActionFailure{PortfolioID: portfolioID, Error: err.Error()})
+			continue
+		}
+		if applied {
+			action.AppliedTo++
+		}
+	}
+
+	return nil
+}
+
+func (cs *CorporateActionStore) GetBySymbol(ctx context.Context, symbol string) ([]CorporateAction, error) {
+	query := `
+		SELECT ca.id, ca.symbol, ca.type, ca.ex_date, ca.pay_date, ca.amount, ca.ratio, ca.new_symbol, ca.currency,
+			(SELECT COUNT(DISTINCT pt.portfolio_id) FROM portfolio_transactions pt WHERE pt.corporate_action_id = ca.id),
+			ca.created_at
+		FROM corporate_actions ca
+		WHERE $1 = '' OR ca.symbol = $1 OR ca.new_symbol = $1
+		ORDER BY ca.ex_date DESC, ca.id DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	rows, err := cs.db.QueryContext(ctx, query, symbol)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []CorporateAction{}
+	for rows.Next() {
+		var a CorporateAction
+
+		err := rows.Scan(
+			&a.ID,
+			&a.Symbol,
+			&a.Type,
+			&a.ExDate,
+			&a.PayDate,
+			&a.Amount,
+			&a.Ratio,
+			&a.NewSymbol,
+			&a.Currency,
+			&a.AppliedTo,
+			&a.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return actions, nil
+}
+
+// renameSymbolReferences moves targets, watchlist items and price alerts from
+// the old symbol of a ticker change to the new one. A target already set on
+// the new symbol takes over the old weight, and a watchlist already listing
+// the new symbol keeps its own item.
+func renameSymbolReferences(ctx context.Context, tx *sql.Tx, oldSymbol, newSymbol string) error {
+	queries := []string{
+		`UPDATE portfolio_targets nt
+		SET weight = nt.weight + ot.weight
+		FROM portfolio_targets ot
+		WHERE nt.portfolio_id = ot.portfolio_id AND nt.symbol = $2 AND ot.symbol = $1`,
+		`DELETE FROM portfolio_targets ot
+		WHERE ot.symbol = $1 AND EXISTS (SELECT 1 FROM portfolio_targets nt WHERE nt.portfolio_id = ot.portfolio_id AND nt.symbol = $2)`,
+		`UPDATE portfolio_targets SET symbol = $2 WHERE symbol = $1`,
+		`DELETE FROM watchlist_items ow
+		WHERE ow.symbol = $1 AND EXISTS (SELECT 1 FROM watchlist_items nw WHERE nw.watchlist_id = ow.watchlist_id AND nw.symbol = $2)`,
+		`UPDATE watchlist_items SET symbol = $2 WHERE symbol = $1`,
+		`UPDATE alerts SET symbol = $2, updated_at = NOW() WHERE kind = 'price' AND symbol = $1`,
+	}
+
+	for _, query := range queries {
+		_, err := tx.ExecContext(ctx, query, oldSymbol, newSymbol)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getActionPortfolios lists the portfolios with ledger entries for the symbol
+// before the ex-date.
+func getActionPortfolios(ctx context.Context, tx *sql.Tx, action *CorporateAction) ([]int64, error) {
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT DISTINCT portfolio_id FROM portfolio_transactions WHERE symbol = $1 AND trade_date < $2`,
+		action.Symbol,
+		action.ExDate.Format(DateLayout),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	portfolioIDs := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		portfolioIDs = append(portfolioIDs, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return portfolioIDs, nil
+}
+
+// applyToPortfolio adjusts one portfolio's ledger for the action and rebuilds
+// the affected positions. It reports false when nothing was held on the
+// ex-date or the split is already in the ledger.
+func applyToPortfolio(ctx context.Context, tx *sql.Tx, portfolioID int64, action *CorporateAction) (bool, error) {
+	transactions, err := getSymbolTransactions(ctx, tx, portfolioID, action.Symbol)
+	if err != nil {
+		return false, err
+	}
+
+	held := []Transaction{}
+	for _, t := range transactions {
+		if t.TradeDate.Before(action.ExDate) {
+			held = append(held, t)
+		}
+	}
+
+	shares, _, err := DerivePosition(held, CostBasisFIFO)
+	if err != nil {
+		return false, err
+	}
+	if shares == 0 {
+		return false, nil
+	}
+
+	currency := action.Currency
+	if currency == "" {
+		currency, err = ledgerCurrency(ctx, tx, portfolioID, action.Symbol)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	switch action.Type {
+	case ActionSplit:
+		// A split already recorded by hand on the ex-date must not apply twice
+		for _, t := range transactions {
+			if t.Type == TransactionSplit && t.TradeDate.Format(DateLayout) == action.ExDate.Format(DateLayout) {
+				return false, nil
+			}
+		}
+
+		err = insertActionTransaction(ctx, tx, action.ID, &Transaction{
+			PortfolioID: portfolioID,
+			Symbol:      action.Symbol,
+			Type:        TransactionSplit,
+			TradeDate:   action.ExDate,
+			Quantity:    action.Ratio,
+		})
+	case ActionDividend:
+		dividend := &Transaction{
+			PortfolioID: portfolioID,
+			Symbol:      action.Symbol,
+			Type:        TransactionDividend,
+			TradeDate:   action.PayDate,
+			Quantity:    shares,
+			Price:       action.Amount,
+			Currency:    currency,
+		}
+		err = insertActionTransaction(ctx, tx, action.ID, dividend)
+		if err != nil {
+			return false, err
+		}
+
+		err = insertCashEntry(ctx, tx, &CashEntry{
+			PortfolioID: portfolioID,
+			Currency:    currency,
+			Type:        CashDividend,
+			Amount:      shares * action.Amount,
+			EntryDate:   action.PayDate,
+			Description: fmt.Sprintf("%s dividend %g x %g", action.Symbol, shares, action.Amount),
+		})
+	case ActionTickerChange:
+		_, err = tx.ExecContext(
+			ctx,
+			`UPDATE portfolio_transactions SET symbol = $1, corporate_action_id = COALESCE(corporate_action_id, $2) WHERE portfolio_id = $3 AND symbol = $4`,
+			action.NewSymbol,
+			action.ID,
+			portfolioID,
+			action.Symbol,
+		)
+		if err != nil {
+			return false, err
+		}
+
+		// The old symbol has no ledger left, so this removes its position
+		_, err = rebuildPosition(ctx, tx, portfolioID, action.Symbol)
+		if err != nil {
+			return false, err
+		}
+
+		_, err = rebuildPosition(ctx, tx, portfolioID, action.NewSymbol)
+		if err != nil {
+			return false, err
+		}
+
+		return true, touchPortfolio(ctx, tx, portfolioID)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	_, err = rebuildPosition(ctx, tx, portfolioID, action.Symbol)
+	if err != nil {
+		return false, err
+	}
+
+	return true, touchPortfolio(ctx, tx, portfolioID)
+}
+
+func insertActionTransaction(ctx context.Context, tx *sql.Tx, actionID int64, transaction *Transaction) error {
+	err := insertTransaction(ctx, tx, transaction)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE portfolio_transactions SET corporate_action_id = $1 WHERE id = $2`, actionID, transaction.ID)
+	return err
+}
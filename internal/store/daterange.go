@@ -0,0 +1,37 @@
+package store
+
+import (
+	"net/http"
+	"time"
+)
+
+const DateLayout = "2006-01-02"
+
+type DateRangeQuery struct {
+	From time.Time `json:"from" validate:"required"`
+	To   time.Time `json:"to" validate:"required,gtefield=From"`
+}
+
+func (drq *DateRangeQuery) Parse(r *http.Request) (*DateRangeQuery, error) {
+	qs := r.URL.Query()
+
+	from := qs.Get("from")
+	if from != "" {
+		f, err := time.Parse(DateLayout, from)
+		if err != nil {
+			return drq, err
+		}
+		drq.From = f
+	}
+
+	to := qs.Get("to")
+	if to != "" {
+		t, err := time.Parse(DateLayout, to)
+		if err != nil {
+			return drq, err
+		}
+		drq.To = t
+	}
+
+	return drq, nil
+}
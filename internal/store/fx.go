@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type FXRate struct {
+	Base  string    `json:"base"`
+	Quote string    `json:"quote"`
+	Date  time.Time `json:"date"`
+	Rate  float64   `json:"rate"`
+}
+
+type FXStore struct {
+	db *sql.DB
+}
+
+// fxChunkSize caps the rows written per statement so loading a full rate
+// history never runs into QueryTimeOut.
+const fxChunkSize = 500
+
+// UpsertRates stores rates, replacing any already stored for the same pair and
+// date. A pair repeated for one date keeps its last rate. Rows are written in
+// chunks, each committed on its own; a failed chunk leaves the earlier ones
+// stored.
+func (fs *FXStore) UpsertRates(ctx context.Context, rates []FXRate) error {
+	type key struct {
+		base, quote, date string
+	}
+
+	index := make(map[key]int, len(rates))
+	unique := make([]FXRate, 0, len(rates))
+	for _, r := range rates {
+		k := key{base: r.Base, quote: r.Quote, date: r.Date.Format(DateLayout)}
+		if i, ok := index[k]; ok {
+			unique[i] = r
+			continue
+		}
+		index[k] = len(unique)
+		unique = append(unique, r)
+	}
+
+	for len(unique) > 0 {
+		n := min(len(unique), fxChunkSize)
+
+		err := fs.upsertRates(ctx, unique[:n])
+		if err != nil {
+			return err
+		}
+		unique = unique[n:]
+	}
+
+	return nil
+}
+
+func (fs *FXStore) upsertRates(ctx context.Context, rates []FXRate) error {
+	query := `
+		INSERT INTO fx_rates (base, quote, rate_date, rate)
+		SELECT t.base, t.quote, t.rate_date::date, t.rate
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::numeric[])
+			AS t(base, quote, rate_date, rate)
+		ON CONFLICT (base, quote, rate_date) DO UPDATE
+		SET rate = EXCLUDED.rate
+	`
+
+	n := len(rates)
+	bases := make([]string, n)
+	quotes := make([]string, n)
+	dates := make([]string, n)
+	values := make([]float64, n)
+
+	for i, r := range rates {
+		bases[i] = r.Base
+		quotes[i] = r.Quote
+		dates[i] = r.Date.Format(DateLayout)
+		values[i] = r.Rate
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	_, err := fs.db.ExecContext(ctx, query, pq.Array(bases), pq.Array(quotes), pq.Array(dates), pq.Array(values))
+
+	return err
+}
+
+// GetRate returns the rate on date or, failing that, the nearest earlier one.
+func (fs *FXStore) GetRate(ctx context.Context, base, quote string, date time.Time) (*FXRate, error) {
+	query := `
+		SELECT base, quote, rate_date, rate
+		FROM fx_rates
+		WHERE base = $1 AND quote = $2 AND rate_date <= $3
+		ORDER BY rate_date DESC
+		LIMIT 1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	var r FXRate
+
+	err := fs.db.QueryRowContext(ctx, query, base, quote, date.Format(DateLayout)).Scan(
+		&r.Base,
+		&r.Quote,
+		&r.Date,
+		&r.Rate,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &r, nil
+}
@@ -0,0 +1,311 @@
+package store
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	CostBasisFIFO    = "fifo"
+	CostBasisLIFO    = "lifo"
+	CostBasisAverage = "average"
+
+	TermShort = "short"
+	TermLong  = "long"
+)
+
+type OpenLot struct {
+	Symbol       string    `json:"symbol"`
+	Quantity     float64   `json:"quantity"`
+	AcquiredDate time.Time `json:"acquired_date"`
+	CostBasis    float64   `json:"cost_basis"`
+}
+
+type ClosedLot struct {
+	Symbol       string    `json:"symbol"`
+	Currency     string    `json:"currency"`
+	Quantity     float64   `json:"quantity"`
+	AcquiredDate time.Time `json:"acquired_date"`
+	SoldDate     time.Time `json:"sold_date"`
+	CostBasis    float64   `json:"cost_basis"`
+	Proceeds     float64   `json:"proceeds"`
+	Gain         float64   `json:"gain"`
+	HoldingDays  int       `json:"holding_days"`
+	Term         string    `json:"term"`
+}
+
+// RealizedTotal sums the gains of the lots closed in one currency.
+type RealizedTotal struct {
+	Currency      string  `json:"currency"`
+	ShortTermGain float64 `json:"short_term_gain"`
+	LongTermGain  float64 `json:"long_term_gain"`
+	TotalGain     float64 `json:"total_gain"`
+}
+
+type RealizedReport struct {
+	Year   int             `json:"year"`
+	Method string          `json:"method"`
+	Lots   []ClosedLot     `json:"lots"`
+	Totals []RealizedTotal `json:"totals"`
+}
+
+// MatchLots replays the ledger and matches sells against buy lots using the
+// given cost basis method. Average cost pools the per-share cost of all open
+// lots before each sell and then consumes them oldest first.
+func MatchLots(transactions []Transaction, method string) ([]OpenLot, []ClosedLot, error) {
+	sorted := make([]Transaction, len(transactions))
+	copy(sorted, transactions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].TradeDate.Before(sorted[j].TradeDate)
+	})
+
+	book := NewLotBook(method)
+	closed := []ClosedLot{}
+
+	for _, t := range sorted {
+		matched, err := book.Apply(t)
+		if err != nil {
+			return nil, nil, err
+		}
+		closed = append(closed, matched...)
+	}
+
+	return book.OpenLots(), closed, nil
+}
+
+// LotBook holds the open lots of a ledger so it can be walked forward one
+// transaction at a time instead of being replayed from the start.
+type LotBook struct {
+	method     string
+	open       map[string][]OpenLot
+	currencies map[string]string
+	symbols    []string
+}
+
+func NewLotBook(method string) *LotBook {
+	return &LotBook{
+		method:     method,
+		open:       map[string][]OpenLot{},
+		currencies: map[string]string{},
+	}
+}
+
+// Apply books a transaction and returns the lots it closed. Transactions must
+// be applied in trade date order.
+func (b *LotBook) Apply(t Transaction) ([]ClosedLot, error) {
+	lots, ok := b.open[t.Symbol]
+	if !ok {
+		b.symbols = append(b.symbols, t.Symbol)
+	}
+	if _, ok := b.currencies[t.Symbol]; !ok && t.Type != TransactionDividend {
+		b.currencies[t.Symbol] = t.Currency
+	}
+
+	var closed []ClosedLot
+
+	switch t.Type {
+	case TransactionBuy:
+		lots = append(lots, OpenLot{
+			Symbol:       t.Symbol,
+			Quantity:     t.Quantity,
+			AcquiredDate: t.TradeDate,
+			CostBasis:    t.Quantity*t.Price + t.Fees,
+		})
+	case TransactionSell:
+		var held float64
+		for _, lot := range lots {
+			held += lot.Quantity
+		}
+		if t.Quantity > held+shareEpsilon {
+			return nil, ErrInsufficientShares
+		}
+
+		if b.method == CostBasisAverage {
+			poolCost(lots)
+		}
+
+		lots, closed = sell(lots, t, b.method)
+		for i := range closed {
+			closed[i].Currency = b.currencies[t.Symbol]
+		}
+	case TransactionSplit:
+		for i := range lots {
+			lots[i].Quantity *= t.Quantity
+		}
+	}
+
+	b.open[t.Symbol] = lots
+
+	return closed, nil
+}
+
+// OpenLots returns the open lots ordered by symbol and acquired date.
+func (b *LotBook) OpenLots() []OpenLot {
+	openLots := []OpenLot{}
+	for _, lots := range b.open {
+		openLots = append(openLots, lots...)
+	}
+	sort.SliceStable(openLots, func(i, j int) bool {
+		if openLots[i].Symbol != openLots[j].Symbol {
+			return openLots[i].Symbol < openLots[j].Symbol
+		}
+		return openLots[i].AcquiredDate.Before(openLots[j].AcquiredDate)
+	})
+
+	return openLots
+}
+
+// Positions returns the symbols still held, in the order they were first
+// booked, with their share count, average price and ledger currency.
+func (b *LotBook) Positions() []Stock {
+	stocks := make([]Stock, 0, len(b.symbols))
+	for _, symbol := range b.symbols {
+		var shares, cost float64
+		for _, lot := range b.open[symbol] {
+			shares += lot.Quantity
+			cost += lot.CostBasis
+		}
+		if shares < shareEpsilon {
+			continue
+		}
+
+		stocks = append(stocks, Stock{
+			Symbol:       symbol,
+			Shares:       shares,
+			AveragePrice: cost / shares,
+			Currency:     b.currencies[symbol],
+		})
+	}
+
+	return stocks
+}
+
+// DerivePosition replays a symbol's ledger and returns the remaining share
+// count and average price of the open lots.
+func DerivePosition(transactions []Transaction, method string) (float64, float64, error) {
+	openLots, _, err := MatchLots(transactions, method)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var shares, cost float64
+	for _, lot := range openLots {
+		shares += lot.Quantity
+		cost += lot.CostBasis
+	}
+
+	if shares < shareEpsilon {
+		return 0, 0, nil
+	}
+
+	return shares, cost / shares, nil
+}
+
+// RealizedGains reports the lots closed during year, or all years when year is
+// 0. Gains are totalled per currency, as lots are never converted.
+func RealizedGains(transactions []Transaction, method string, year int) (*RealizedReport, error) {
+	_, closed, err := MatchLots(transactions, method)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RealizedReport{
+		Year:   year,
+		Method: method,
+		Lots:   []ClosedLot{},
+		Totals: []RealizedTotal{},
+	}
+
+	byCurrency := map[string]int{}
+	for _, lot := range closed {
+		if year != 0 && lot.SoldDate.Year() != year {
+			continue
+		}
+
+		report.Lots = append(report.Lots, lot)
+
+		i, ok := byCurrency[lot.Currency]
+		if !ok {
+			i = len(report.Totals)
+			byCurrency[lot.Currency] = i
+			report.Totals = append(report.Totals, RealizedTotal{Currency: lot.Currency})
+		}
+
+		total := &report.Totals[i]
+		total.TotalGain += lot.Gain
+		if lot.Term == TermLong {
+			total.LongTermGain += lot.Gain
+		} else {
+			total.ShortTermGain += lot.Gain
+		}
+	}
+
+	sort.Slice(report.Totals, func(i, j int) bool {
+		return report.Totals[i].Currency < report.Totals[j].Currency
+	})
+
+	return report, nil
+}
+
+func sell(lots []OpenLot, t Transaction, method string) ([]OpenLot, []ClosedLot) {
+	closed := []ClosedLot{}
+	proceedsPerShare := (t.Quantity*t.Price - t.Fees) / t.Quantity
+	remaining := t.Quantity
+
+	for remaining > shareEpsilon && len(lots) > 0 {
+		i := 0
+		if method == CostBasisLIFO {
+			i = len(lots) - 1
+		}
+		lot := &lots[i]
+
+		quantity := min(remaining, lot.Quantity)
+		costBasis := lot.CostBasis / lot.Quantity * quantity
+		proceeds := proceedsPerShare * quantity
+
+		closed = append(closed, ClosedLot{
+			Symbol:       t.Symbol,
+			Quantity:     quantity,
+			AcquiredDate: lot.AcquiredDate,
+			SoldDate:     t.TradeDate,
+			CostBasis:    costBasis,
+			Proceeds:     proceeds,
+			Gain:         proceeds - costBasis,
+			HoldingDays:  int(t.TradeDate.Sub(lot.AcquiredDate).Hours() / 24),
+			Term:         holdingTerm(lot.AcquiredDate, t.TradeDate),
+		})
+
+		lot.Quantity -= quantity
+		lot.CostBasis -= costBasis
+		remaining -= quantity
+
+		if lot.Quantity < shareEpsilon {
+			lots = append(lots[:i], lots[i+1:]...)
+		}
+	}
+
+	return lots, closed
+}
+
+func poolCost(lots []OpenLot) {
+	var shares, cost float64
+	for _, lot := range lots {
+		shares += lot.Quantity
+		cost += lot.CostBasis
+	}
+	if shares == 0 {
+		return
+	}
+
+	for i := range lots {
+		lots[i].CostBasis = cost / shares * lots[i].Quantity
+	}
+}
+
+// holdingTerm is long when the lot was held for more than one year.
+func holdingTerm(acquired, sold time.Time) string {
+	if sold.After(acquired.AddDate(1, 0, 0)) {
+		return TermLong
+	}
+	return TermShort
+}
@@ -0,0 +1,235 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	d, _ := time.Parse(DateLayout, s)
+	return d
+}
+
+func trade(kind, date string, quantity, price float64) Transaction {
+	return Transaction{
+		Symbol:    "AAPL",
+		Type:      kind,
+		TradeDate: day(date),
+		Quantity:  quantity,
+		Price:     price,
+		Currency:  "USD",
+	}
+}
+
+func TestMatchLots(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		transactions []Transaction
+		wantShares   float64
+		wantAverage  float64
+		wantClosed   []ClosedLot
+		err          error
+	}{
+		{
+			name:   "fifo sells oldest lots first",
+			method: CostBasisFIFO,
+			transactions: []Transaction{
+				trade(TransactionBuy, "2024-01-02", 10, 10),
+				trade(TransactionBuy, "2024-02-01", 10, 20),
+				trade(TransactionSell, "2024-03-01", 15, 30),
+			},
+			wantShares:  5,
+			wantAverage: 20,
+			wantClosed: []ClosedLot{
+				{Quantity: 10, AcquiredDate: day("2024-01-02"), CostBasis: 100, Proceeds: 300, Gain: 200, Term: TermShort},
+				{Quantity: 5, AcquiredDate: day("2024-02-01"), CostBasis: 100, Proceeds: 150, Gain: 50, Term: TermShort},
+			},
+		},
+		{
+			name:   "lifo sells newest lots first",
+			method: CostBasisLIFO,
+			transactions: []Transaction{
+				trade(TransactionBuy, "2024-01-02", 10, 10),
+				trade(TransactionBuy, "2024-02-01", 10, 20),
+				trade(TransactionSell, "2024-03-01", 15, 30),
+			},
+			wantShares:  5,
+			wantAverage: 10,
+			wantClosed: []ClosedLot{
+				{Quantity: 10, AcquiredDate: day("2024-02-01"), CostBasis: 200, Proceeds: 300, Gain: 100, Term: TermShort},
+				{Quantity: 5, AcquiredDate: day("2024-01-02"), CostBasis: 50, Proceeds: 150, Gain: 100, Term: TermShort},
+			},
+		},
+		{
+			name:   "average cost pools lots before selling",
+			method: CostBasisAverage,
+			transactions: []Transaction{
+				trade(TransactionBuy, "2024-01-02", 10, 10),
+				trade(TransactionBuy, "2024-02-01", 10, 20),
+				trade(TransactionSell, "2024-03-01", 15, 30),
+			},
+			wantShares:  5,
+			wantAverage: 15,
+			wantClosed: []ClosedLot{
+				{Quantity: 10, AcquiredDate: day("2024-01-02"), CostBasis: 150, Proceeds: 300, Gain: 150, Term: TermShort},
+				{Quantity: 5, AcquiredDate: day("2024-02-01"), CostBasis: 75, Proceeds: 150, Gain: 75, Term: TermShort},
+			},
+		},
+		{
+			name:   "partial sells with fees",
+			method: CostBasisFIFO,
+			transactions: []Transaction{
+				{Symbol: "AAPL", Type: TransactionBuy, TradeDate: day("2024-01-02"), Quantity: 10, Price: 10, Fees: 10, Currency: "USD"},
+				{Symbol: "AAPL", Type: TransactionSell, TradeDate: day("2024-02-01"), Quantity: 4, Price: 20, Fees: 8, Currency: "USD"},
+				{Symbol: "AAPL", Type: TransactionSell, TradeDate: day("2024-03-01"), Quantity: 2, Price: 25, Currency: "USD"},
+			},
+			wantShares:  4,
+			wantAverage: 11,
+			wantClosed: []ClosedLot{
+				{Quantity: 4, AcquiredDate: day("2024-01-02"), CostBasis: 44, Proceeds: 72, Gain: 28, Term: TermShort},
+				{Quantity: 2, AcquiredDate: day("2024-01-02"), CostBasis: 22, Proceeds: 50, Gain: 28, Term: TermShort},
+			},
+		},
+		{
+			name:   "split mid-holding",
+			method: CostBasisFIFO,
+			transactions: []Transaction{
+				trade(TransactionBuy, "2024-01-02", 10, 100),
+				trade(TransactionSplit, "2024-02-01", 2, 0),
+				trade(TransactionBuy, "2024-02-15", 10, 60),
+				trade(TransactionSell, "2024-03-01", 25, 70),
+			},
+			wantShares:  5,
+			wantAverage: 60,
+			wantClosed: []ClosedLot{
+				{Quantity: 20, AcquiredDate: day("2024-01-02"), CostBasis: 1000, Proceeds: 1400, Gain: 400, Term: TermShort},
+				{Quantity: 5, AcquiredDate: day("2024-02-15"), CostBasis: 300, Proceeds: 350, Gain: 50, Term: TermShort},
+			},
+		},
+		{
+			name:   "sold out position",
+			method: CostBasisFIFO,
+			transactions: []Transaction{
+				trade(TransactionBuy, "2024-01-02", 10, 10),
+				trade(TransactionSell, "2024-02-01", 10, 12),
+			},
+			wantShares:  0,
+			wantAverage: 0,
+			wantClosed: []ClosedLot{
+				{Quantity: 10, AcquiredDate: day("2024-01-02"), CostBasis: 100, Proceeds: 120, Gain: 20, Term: TermShort},
+			},
+		},
+		{
+			name:   "one year to the day is short term",
+			method: CostBasisFIFO,
+			transactions: []Transaction{
+				trade(TransactionBuy, "2023-03-01", 1, 10),
+				trade(TransactionSell, "2024-03-01", 1, 10),
+			},
+			wantClosed: []ClosedLot{
+				{Quantity: 1, AcquiredDate: day("2023-03-01"), CostBasis: 10, Proceeds: 10, Term: TermShort},
+			},
+		},
+		{
+			name:   "one year and a day is long term",
+			method: CostBasisFIFO,
+			transactions: []Transaction{
+				trade(TransactionBuy, "2023-03-01", 1, 10),
+				trade(TransactionSell, "2024-03-02", 1, 10),
+			},
+			wantClosed: []ClosedLot{
+				{Quantity: 1, AcquiredDate: day("2023-03-01"), CostBasis: 10, Proceeds: 10, Term: TermLong},
+			},
+		},
+		{
+			name:   "oversold ledger",
+			method: CostBasisFIFO,
+			transactions: []Transaction{
+				trade(TransactionBuy, "2024-01-02", 5, 10),
+				trade(TransactionSell, "2024-02-01", 6, 10),
+			},
+			err: ErrInsufficientShares,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, closed, err := MatchLots(tt.transactions, tt.method)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got error %v, want %v", err, tt.err)
+				}
+
+				_, _, err = DerivePosition(tt.transactions, tt.method)
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("DerivePosition got error %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(closed) != len(tt.wantClosed) {
+				t.Fatalf("got %d closed lots, want %d", len(closed), len(tt.wantClosed))
+			}
+			for i, want := range tt.wantClosed {
+				got := closed[i]
+				if !equal(got.Quantity, want.Quantity) ||
+					!got.AcquiredDate.Equal(want.AcquiredDate) ||
+					!equal(got.CostBasis, want.CostBasis) ||
+					!equal(got.Proceeds, want.Proceeds) ||
+					!equal(got.Gain, want.Gain) ||
+					got.Term != want.Term {
+					t.Errorf("closed lot %d: got %+v, want %+v", i, got, want)
+				}
+				if got.Currency != "USD" {
+					t.Errorf("closed lot %d: got currency %q, want USD", i, got.Currency)
+				}
+			}
+
+			shares, average, err := DerivePosition(tt.transactions, tt.method)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !equal(shares, tt.wantShares) || !equal(average, tt.wantAverage) {
+				t.Errorf("got %v shares at %v, want %v at %v", shares, average, tt.wantShares, tt.wantAverage)
+			}
+		})
+	}
+}
+
+func equal(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestRealizedGainsTotalsPerCurrency(t *testing.T) {
+	transactions := []Transaction{
+		{Symbol: "AAPL", Type: TransactionBuy, TradeDate: day("2022-01-03"), Quantity: 10, Price: 10, Currency: "USD"},
+		{Symbol: "SAP", Type: TransactionBuy, TradeDate: day("2024-01-02"), Quantity: 10, Price: 100, Currency: "EUR"},
+		{Symbol: "AAPL", Type: TransactionSell, TradeDate: day("2024-02-01"), Quantity: 10, Price: 15, Currency: "USD"},
+		{Symbol: "SAP", Type: TransactionSell, TradeDate: day("2024-03-01"), Quantity: 5, Price: 90, Currency: "EUR"},
+	}
+
+	report, err := RealizedGains(transactions, CostBasisFIFO, 2024)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []RealizedTotal{
+		{Currency: "EUR", ShortTermGain: -50, TotalGain: -50},
+		{Currency: "USD", LongTermGain: 50, TotalGain: 50},
+	}
+	if len(report.Totals) != len(want) {
+		t.Fatalf("got %d totals, want %d", len(report.Totals), len(want))
+	}
+	for i, w := range want {
+		got := report.Totals[i]
+		if got.Currency != w.Currency || !equal(got.ShortTermGain, w.ShortTermGain) || !equal(got.LongTermGain, w.LongTermGain) || !equal(got.TotalGain, w.TotalGain) {
+			t.Errorf("total %d: got %+v, want %+v", i, got, w)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleOwner  = "owner"
+)
+
+var (
+	ErrAlreadyMember = errors.New("user is already a member of the portfolio")
+	ErrLastOwner     = errors.New("portfolio must keep at least one owner")
+
+	roleRank = map[string]int{
+		RoleViewer: 1,
+		RoleEditor: 2,
+		RoleOwner:  3,
+	}
+)
+
+// HasRole reports whether role grants at least the permissions of required.
+func HasRole(role, required string) bool {
+	return roleRank[role] >= roleRank[required] && roleRank[required] > 0
+}
+
+// rolesAtLeast lists the roles that grant at least the permissions of required.
+func rolesAtLeast(required string) []string {
+	roles := []string{}
+	for _, role := range []string{RoleViewer, RoleEditor, RoleOwner} {
+		if HasRole(role, required) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+type Member struct {
+	PortfolioID int64  `json:"portfolio_id"`
+	UserID      int64  `json:"user_id"`
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	Role        string `json:"role"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type PortfolioInvitation struct {
+	PortfolioID int64     `json:"portfolio_id"`
+	Email       string    `json:"email"`
+	Role        string    `json:"role"`
+	InvitedBy   int64     `json:"invited_by"`
+	Expiry      time.Time `json:"expiry"`
+}
+
+type MemberStore struct {
+	db *sql.DB
+}
+
+func (ms *MemberStore) GetByPortfolio(ctx context.Context, portfolioID int64) ([]Member, error) {
+	query := `
+		SELECT pm.portfolio_id, pm.user_id, u.username, u.email, pm.role, pm.created_at
+		FROM portfolio_members pm
+		JOIN users u ON u.id = pm.user_id
+		WHERE pm.portfolio_id = $1
+		ORDER BY pm.created_at ASC, pm.user_id ASC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	rows, err := ms.db.QueryContext(ctx, query, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []Member{}
+	for rows.Next() {
+		var m Member
+
+		err := rows.Scan(
+			&m.PortfolioID,
+			&m.UserID,
+			&m.Username,
+			&m.Email,
+			&m.Role,
+			&m.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func (ms *MemberStore) GetRole(ctx context.Context, portfolioID int64, userID int64) (string, error) {
+	query := `SELECT role FROM portfolio_members WHERE portfolio_id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	var role string
+	err := ms.db.QueryRowContext(ctx, query, portfolioID, userID).Scan(&role)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrNotFound
+		default:
+			return "", err
+		}
+	}
+
+	return role, nil
+}
+
+// Invite stores a hashed invitation token for email, replacing any pending
+// invitation to the same portfolio.
+func (ms *MemberStore) Invite(ctx context.Context, invitation *PortfolioInvitation, token string, invitationEXP time.Duration) error {
+	return withTX(ms.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+		defer cancel()
+
+		var member bool
+		memberQuery := `
+			SELECT EXISTS(
+				SELECT 1
+				FROM portfolio_members pm
+				JOIN users u ON u.id = pm.user_id
+				WHERE pm.portfolio_id = $1 AND u.email = $2
+			)
+		`
+		err := tx.QueryRowContext(ctx, memberQuery, invitation.PortfolioID, invitation.Email).Scan(&member)
+		if err != nil {
+			return err
+		}
+		if member {
+			return ErrAlreadyMember
+		}
+
+		invitation.Expiry = time.Now().Add(invitationEXP)
+
+		query := `
+			INSERT INTO portfolio_invitations (token, portfolio_id, email, role, invited_by, expiry)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (portfolio_id, email) DO UPDATE
+			SET token = EXCLUDED.token,
+				role = EXCLUDED.role,
+				invited_by = EXCLUDED.invited_by,
+				expiry = EXCLUDED.expiry,
+				created_at = NOW()
+		`
+		_, err = tx.ExecContext(ctx, query, token, invitation.PortfolioID, invitation.Email, invitation.Role, invitation.InvitedBy, invitation.Expiry)
+		return err
+	})
+}
+
+// DeleteInvitation drops the invitation stored under token, for when its email
+// could not be sent.
+func (ms *MemberStore) DeleteInvitation(ctx context.Context, token string) error {
+	query := `DELETE FROM portfolio_invitations WHERE token = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	_, err := ms.db.ExecContext(ctx, query, token)
+	return err
+}
+
+// Accept adds user to the portfolio of an unexpired invitation addressed to
+// their email and consumes the invitation.
+func (ms *MemberStore) Accept(ctx context.Context, token string, user *User) (*Member, error) {
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	member := &Member{
+		UserID:   user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+	}
+
+	err := withTX(ms.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+		defer cancel()
+
+		query := `
+			DELETE FROM portfolio_invitations
+			WHERE token = $1 AND email = $2 AND expiry > $3
+			RETURNING portfolio_id, role
+		`
+		err := tx.QueryRowContext(ctx, query, hashToken, user.Email, time.Now()).Scan(
+			&member.PortfolioID,
+			&member.Role,
+		)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		insertQuery := `
+			INSERT INTO portfolio_members (portfolio_id, user_id, role)
+			VALUES ($1, $2, $3)
+			RETURNING created_at
+		`
+		err = tx.QueryRowContext(ctx, insertQuery, member.PortfolioID, member.UserID, member.Role).Scan(&member.CreatedAt)
+		if err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Constraint == "portfolio_members_pkey" {
+				return ErrAlreadyMember
+			}
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+func (ms *MemberStore) UpdateRole(ctx context.Context, portfolioID int64, userID int64, role string) error {
+	return withTX(ms.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+		defer cancel()
+
+		if role != RoleOwner {
+			err := checkOtherOwner(ctx, tx, portfolioID, userID)
+			if err != nil {
+				return err
+			}
+		}
+
+		query := `UPDATE portfolio_members SET role = $1 WHERE portfolio_id = $2 AND user_id = $3`
+		result, err := tx.ExecContext(ctx, query, role, portfolioID, userID)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		return nil
+	})
+}
+
+func (ms *MemberStore) Remove(ctx context.Context, portfolioID int64, userID int64) error {
+	return withTX(ms.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+		defer cancel()
+
+		err := checkOtherOwner(ctx, tx, portfolioID, userID)
+		if err != nil {
+			return err
+		}
+
+		query := `DELETE FROM portfolio_members WHERE portfolio_id = $1 AND user_id = $2`
+		result, err := tx.ExecContext(ctx, query, portfolioID, userID)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		return nil
+	})
+}
+
+// checkOtherOwner fails with ErrLastOwner when userID is the only owner left.
+// The owner rows are locked so concurrent demotions cannot both pass.
+func checkOtherOwner(ctx context.Context, tx *sql.Tx, portfolioID int64, userID int64) error {
+	query := `
+		SELECT user_id
+		FROM portfolio_members
+		WHERE portfolio_id = $1 AND role = $2
+		FOR UPDATE
+	`
+
+	rows, err := tx.QueryContext(ctx, query, portfolioID, RoleOwner)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	owners := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		owners = append(owners, id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(owners) == 1 && owners[0] == userID {
+		return ErrLastOwner
+	}
+
+	return nil
+}
+
+func addMember(ctx context.Context, tx *sql.Tx, portfolioID int64, userID int64, role string) error {
+	query := `
+		INSERT INTO portfolio_members (portfolio_id, user_id, role)
+		VALUES ($1, $2, $3)
+	`
+
+	_, err := tx.ExecContext(ctx, query, portfolioID, userID, role)
+	return err
+}
@@ -0,0 +1,227 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrMFAEnabled = errors.New("two-factor authentication is already enabled")
+	ErrCodeUsed   = errors.New("code has already been used")
+)
+
+type MFA struct {
+	UserID       int64      `json:"user_id"`
+	Secret       string     `json:"-"`
+	EnabledAt    *time.Time `json:"enabled_at"`
+	LastUsedStep int64      `json:"-"`
+	CreatedAt    string     `json:"created_at"`
+}
+
+func (m *MFA) Enabled() bool {
+	return m.EnabledAt != nil
+}
+
+type MFAStore struct {
+	db *sql.DB
+}
+
+func (ms *MFAStore) Get(ctx context.Context, userID int64) (*MFA, error) {
+	query := `
+		SELECT user_id, secret, enabled_at, last_used_step, created_at
+		FROM user_mfa
+		WHERE user_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	mfa := &MFA{}
+
+	err := ms.db.QueryRowContext(ctx, query, userID).Scan(
+		&mfa.UserID,
+		&mfa.Secret,
+		&mfa.EnabledAt,
+		&mfa.LastUsedStep,
+		&mfa.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return mfa, nil
+}
+
+// Enroll stores a new pending secret for the user, replacing an earlier
+// enrollment that was never verified.
+func (ms *MFAStore) Enroll(ctx context.Context, userID int64, secret string) error {
+	query := `
+		INSERT INTO user_mfa (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+		WHERE user_mfa.enabled_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	res, err := ms.db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrMFAEnabled
+	}
+
+	return nil
+}
+
+// Enable turns on a pending enrollment once its first code is verified at
+// step and stores the hashed recovery codes.
+func (ms *MFAStore) Enable(ctx context.Context, userID int64, step int64, recoveryCodes []string) error {
+	return withTX(ms.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+		defer cancel()
+
+		query := `
+			UPDATE user_mfa
+			SET enabled_at = NOW(), last_used_step = $2
+			WHERE user_id = $1 AND enabled_at IS NULL
+		`
+		res, err := tx.ExecContext(ctx, query, userID, step)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrMFAEnabled
+		}
+
+		return replaceRecoveryCodes(ctx, tx, userID, recoveryCodes)
+	})
+}
+
+// UseStep records a verified code so its time step cannot be replayed.
+func (ms *MFAStore) UseStep(ctx context.Context, userID int64, step int64) error {
+	query := `
+		UPDATE user_mfa
+		SET last_used_step = $2
+		WHERE user_id = $1 AND enabled_at IS NOT NULL AND last_used_step < $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	res, err := ms.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrCodeUsed
+	}
+
+	return nil
+}
+
+// UseRecoveryCode consumes one of the user's unused recovery codes.
+func (ms *MFAStore) UseRecoveryCode(ctx context.Context, userID int64, hashedCode string) error {
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code = $2 AND used_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	res, err := ms.db.ExecContext(ctx, query, userID, hashedCode)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces every recovery code of the user.
+func (ms *MFAStore) RegenerateRecoveryCodes(ctx context.Context, userID int64, recoveryCodes []string) error {
+	return withTX(ms.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+		defer cancel()
+
+		return replaceRecoveryCodes(ctx, tx, userID, recoveryCodes)
+	})
+}
+
+func (ms *MFAStore) Disable(ctx context.Context, userID int64) error {
+	return withTX(ms.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM user_mfa WHERE user_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		return nil
+	})
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int64, recoveryCodes []string) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO mfa_recovery_codes (code, user_id)
+		SELECT c.code::bytea, $2
+		FROM unnest($1::text[]) AS c(code)
+	`
+	_, err = tx.ExecContext(ctx, query, pq.Array(recoveryCodes), userID)
+	return err
+}
@@ -41,3 +41,39 @@ func (pfq *PaginatedFeedQuery) Parse(r *http.Request) (*PaginatedFeedQuery, erro
 
 	return pfq, nil
 }
+
+type UserFilterQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=100"`
+	Offset int    `json:"offset" validate:"gte=0"`
+	Search string `json:"search" validate:"max=100"`
+	Role   string `json:"role" validate:"omitempty,oneof=user support admin"`
+	Status string `json:"status" validate:"omitempty,oneof=active pending deactivated"`
+}
+
+func (ufq *UserFilterQuery) Parse(r *http.Request) (*UserFilterQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return nil, err
+		}
+		ufq.Limit = l
+	}
+
+	offset := qs.Get("offset")
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return nil, err
+		}
+		ufq.Offset = o
+	}
+
+	ufq.Search = qs.Get("search")
+	ufq.Role = qs.Get("role")
+	ufq.Status = qs.Get("status")
+
+	return ufq, nil
+}
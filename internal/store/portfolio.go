@@ -5,18 +5,24 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"math"
+	"time"
 
 	"github.com/lib/pq"
 )
 
 type Portfolio struct {
-	ID        int64   `json:"id"`
-	UserID    int64   `json:"user_id"`
-	Name      string  `json:"name"`
-	Stocks    []Stock `json:"stocks"`
-	Version   int     `json:"version"`
-	CreatedAt string  `json:"created_at"`
-	UpdatedAt string  `json:"updated_at"`
+	ID              int64   `json:"id"`
+	UserID          int64   `json:"user_id"`
+	Name            string  `json:"name"`
+	CostBasisMethod string  `json:"cost_basis_method"`
+	BaseCurrency    string  `json:"base_currency"`
+	BenchmarkSymbol string  `json:"benchmark_symbol"`
+	Role            string  `json:"role"`
+	Stocks          []Stock `json:"stocks"`
+	Version         int     `json:"version"`
+	CreatedAt       string  `json:"created_at"`
+	UpdatedAt       string  `json:"updated_at"`
 }
 
 type PortfolioStore struct {
@@ -25,15 +31,22 @@ type PortfolioStore struct {
 
 func (ps *PortfolioStore) Create(ctx context.Context, tx *sql.Tx, portfolio *Portfolio) error {
 	query := `
-		INSERT INTO portfolios (user_id, name, created_at, updated_at)
-		VALUES ($1, $2, NOW(), NOW())
+		INSERT INTO portfolios (user_id, name, cost_basis_method, base_currency, benchmark_symbol, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
 		RETURNING id
 	`
 
+	if portfolio.CostBasisMethod == "" {
+		portfolio.CostBasisMethod = CostBasisFIFO
+	}
+	if portfolio.BaseCurrency == "" {
+		portfolio.BaseCurrency = DefaultCurrency
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
 	defer cancel()
 
-	err := tx.QueryRowContext(ctx, query, portfolio.UserID, portfolio.Name).Scan(
+	err := tx.QueryRowContext(ctx, query, portfolio.UserID, portfolio.Name, portfolio.CostBasisMethod, portfolio.BaseCurrency, portfolio.BenchmarkSymbol).Scan(
 		&portfolio.ID,
 	)
 
@@ -41,19 +54,20 @@ func (ps *PortfolioStore) Create(ctx context.Context, tx *sql.Tx, portfolio *Por
 		return err
 	}
 
+	err = addMember(ctx, tx, portfolio.ID, portfolio.UserID, RoleOwner)
+	if err != nil {
+		return err
+	}
+	portfolio.Role = RoleOwner
+
 	for i := range portfolio.Stocks {
 		stock := &portfolio.Stocks[i]
-		stock.PortfolioID = portfolio.ID
-
-		stockQuery := `
-			INSERT INTO portfolio_stocks (portfolio_id, symbol, shares, average_price, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, NOW(), NOW())
-		`
-		_, err := tx.ExecContext(ctx, stockQuery, stock.PortfolioID, stock.Symbol, stock.Shares, stock.AveragePrice)
 
+		position, err := openPosition(ctx, tx, portfolio.ID, stock)
 		if err != nil {
 			return err
 		}
+		*stock = *position
 	}
 
 	return nil
@@ -75,10 +89,11 @@ func (ps *PortfolioStore) GetPortfolios(ctx context.Context, userID int64, pfq *
 
 	query := fmt.Sprintf(
 		`
-		SELECT id, user_id, name, created_at, updated_at 
-		FROM portfolios
-		WHERE user_id = $1
-		ORDER BY updated_at %s
+		SELECT p.id, p.user_id, p.name, p.cost_basis_method, p.base_currency, p.benchmark_symbol, pm.role, p.created_at, p.updated_at
+		FROM portfolios p
+		JOIN portfolio_members pm ON pm.portfolio_id = p.id
+		WHERE pm.user_id = $1
+		ORDER BY p.updated_at %s
 		LIMIT $2 OFFSET $3
 	`,
 		pfq.Sort,
@@ -103,6 +118,10 @@ func (ps *PortfolioStore) GetPortfolios(ctx context.Context, userID int64, pfq *
 			&p.ID,
 			&p.UserID,
 			&p.Name,
+			&p.CostBasisMethod,
+			&p.BaseCurrency,
+			&p.BenchmarkSymbol,
+			&p.Role,
 			&p.CreatedAt,
 			&p.UpdatedAt,
 		)
@@ -118,13 +137,92 @@ func (ps *PortfolioStore) GetPortfolios(ctx context.Context, userID int64, pfq *
 	return portfolios, nil
 }
 
-func (ps *PortfolioStore) SearchPortfoliosByName(ctx context.Context, userId int64, searchParam string) ([]*Portfolio, error) {
+// GetPortfolioIDs lists the portfolios in which the user holds at least role.
+func (ps *PortfolioStore) GetPortfolioIDs(ctx context.Context, userID int64, role string) ([]int64, error) {
+	query := `
+		SELECT portfolio_id
+		FROM portfolio_members
+		WHERE user_id = $1 AND role = ANY($2)
+		ORDER BY portfolio_id ASC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	rows, err := ps.db.QueryContext(ctx, query, userID, pq.Array(rolesAtLeast(role)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// GetAll lists every portfolio without its stocks, for background jobs.
+func (ps *PortfolioStore) GetAll(ctx context.Context) ([]*Portfolio, error) {
 	query := `
-		SELECT id, user_id, name, created_at, updated_at
+		SELECT id, user_id, name, cost_basis_method, base_currency, benchmark_symbol, created_at, updated_at
 		FROM portfolios
-		WHERE user_id = $1 AND name ILIKE $2
-		ORDER BY updated_at DESC
+		ORDER BY id ASC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	rows, err := ps.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	portfolios := []*Portfolio{}
+	for rows.Next() {
+		var p Portfolio
+
+		err := rows.Scan(
+			&p.ID,
+			&p.UserID,
+			&p.Name,
+			&p.CostBasisMethod,
+			&p.BaseCurrency,
+			&p.BenchmarkSymbol,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		portfolios = append(portfolios, &p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return portfolios, nil
+}
+
+func (ps *PortfolioStore) SearchPortfoliosByName(ctx context.Context, userId int64, searchParam string) ([]*Portfolio, error) {
+
+	query := `
+		SELECT p.id, p.user_id, p.name, p.cost_basis_method, p.base_currency, p.benchmark_symbol, pm.role, p.created_at, p.updated_at
+		FROM portfolios p
+		JOIN portfolio_members pm ON pm.portfolio_id = p.id
+		WHERE pm.user_id = $1 AND p.name ILIKE $2
+		ORDER BY p.updated_at DESC
 	`
 
 	searchPattern := "%" + searchParam + "%"
@@ -147,6 +245,10 @@ func (ps *PortfolioStore) SearchPortfoliosByName(ctx context.Context, userId int
 			&p.ID,
 			&p.UserID,
 			&p.Name,
+			&p.CostBasisMethod,
+			&p.BaseCurrency,
+			&p.BenchmarkSymbol,
+			&p.Role,
 			&p.CreatedAt,
 			&p.UpdatedAt,
 		)
@@ -156,7 +258,7 @@ func (ps *PortfolioStore) SearchPortfoliosByName(ctx context.Context, userId int
 		}
 
 		stockQuery := `
-			SELECT id, portfolio_id, symbol, shares, average_price, created_at, updated_at
+			SELECT id, portfolio_id, symbol, shares, average_price, currency, created_at, updated_at
 			FROM portfolio_stocks
 			WHERE portfolio_id = $1
 			ORDER BY symbol ASC
@@ -179,6 +281,7 @@ func (ps *PortfolioStore) SearchPortfoliosByName(ctx context.Context, userId int
 				&stock.Symbol,
 				&stock.Shares,
 				&stock.AveragePrice,
+				&stock.Currency,
 				&stock.CreatedAt,
 				&stock.UpdatedAt,
 			)
@@ -207,9 +310,10 @@ func (ps *PortfolioStore) SearchPortfoliosByName(ctx context.Context, userId int
 func (ps *PortfolioStore) GetPortfolioByID(ctx context.Context, portfolioID int64, userID int64) (*Portfolio, error) {
 
 	query := `
-		SELECT id, user_id, name, version, created_at, updated_at
-		FROM portfolios
-		WHERE id = $1 AND user_id = $2
+		SELECT p.id, p.user_id, p.name, p.cost_basis_method, p.base_currency, p.benchmark_symbol, pm.role, p.version, p.created_at, p.updated_at
+		FROM portfolios p
+		JOIN portfolio_members pm ON pm.portfolio_id = p.id
+		WHERE p.id = $1 AND pm.user_id = $2
 	`
 	var p Portfolio
 
@@ -219,6 +323,10 @@ func (ps *PortfolioStore) GetPortfolioByID(ctx context.Context, portfolioID int6
 		&p.ID,
 		&p.UserID,
 		&p.Name,
+		&p.CostBasisMethod,
+		&p.BaseCurrency,
+		&p.BenchmarkSymbol,
+		&p.Role,
 		&p.Version,
 		&p.CreatedAt,
 		&p.UpdatedAt,
@@ -236,7 +344,7 @@ func (ps *PortfolioStore) GetPortfolioByID(ctx context.Context, portfolioID int6
 	//Get stocks due to transaction for creation
 
 	stockQuery := `
-		SELECT id, portfolio_id, symbol, shares, average_price, created_at, updated_at
+		SELECT id, portfolio_id, symbol, shares, average_price, currency, created_at, updated_at
 		FROM portfolio_stocks
 		WHERE portfolio_id = $1
 		ORDER BY symbol ASC
@@ -259,6 +367,7 @@ func (ps *PortfolioStore) GetPortfolioByID(ctx context.Context, portfolioID int6
 			&stock.Symbol,
 			&stock.Shares,
 			&stock.AveragePrice,
+			&stock.Currency,
 			&stock.CreatedAt,
 			&stock.UpdatedAt,
 		)
@@ -298,19 +407,33 @@ func (ps *PortfolioStore) UpdatePortfolio(ctx context.Context, portfolio *Portfo
 
 	query := `
 		UPDATE portfolios 
-		SET name = $1, version = version + 1, updated_at = NOW()
-		WHERE id = $2 AND user_id = $3 AND  version = $4
-		RETURNING id, user_id, name, version, created_at, updated_at
+		SET name = $1, cost_basis_method = $2, base_currency = $3, benchmark_symbol = $4, version = version + 1, updated_at = NOW()
+		WHERE id = $5 AND version = $7 AND EXISTS(
+			SELECT 1 FROM portfolio_members
+			WHERE portfolio_id = $5 AND user_id = $6 AND role IN ('editor', 'owner')
+		)
+		RETURNING id, user_id, name, cost_basis_method, base_currency, benchmark_symbol, version, created_at, updated_at
 	`
 
-	err = ps.db.QueryRowContext(ctx, query, portfolio.Name, portfolio.ID, userID, portfolio.Version).Scan(
-		&portfolio.ID,
-		&portfolio.UserID,
-		&portfolio.Name,
-		&portfolio.Version,
-		&portfolio.CreatedAt,
-		&portfolio.UpdatedAt,
-	)
+	// Positions depend on the cost basis method, so they are rebuilt with it.
+	err = withTX(ps.db, ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, portfolio.Name, portfolio.CostBasisMethod, portfolio.BaseCurrency, portfolio.BenchmarkSymbol, portfolio.ID, userID, portfolio.Version).Scan(
+			&portfolio.ID,
+			&portfolio.UserID,
+			&portfolio.Name,
+			&portfolio.CostBasisMethod,
+			&portfolio.BaseCurrency,
+			&portfolio.BenchmarkSymbol,
+			&portfolio.Version,
+			&portfolio.CreatedAt,
+			&portfolio.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		return rebuildPositions(ctx, tx, portfolio.ID)
+	})
 
 	if err != nil {
 		switch {
@@ -322,7 +445,7 @@ func (ps *PortfolioStore) UpdatePortfolio(ctx context.Context, portfolio *Portfo
 	}
 
 	stockQuery := `
-		SELECT id, portfolio_id, symbol, shares, average_price, created_at, updated_at
+		SELECT id, portfolio_id, symbol, shares, average_price, currency, created_at, updated_at
 		FROM portfolio_stocks
 		WHERE portfolio_id = $1
 		ORDER BY symbol ASC
@@ -344,6 +467,7 @@ func (ps *PortfolioStore) UpdatePortfolio(ctx context.Context, portfolio *Portfo
 			&stock.Symbol,
 			&stock.Shares,
 			&stock.AveragePrice,
+			&stock.Currency,
 			&stock.CreatedAt,
 			&stock.UpdatedAt,
 		)
@@ -365,7 +489,10 @@ func (ps *PortfolioStore) UpdatePortfolio(ctx context.Context, portfolio *Portfo
 func (ps *PortfolioStore) DeletePortfolio(ctx context.Context, portfolioID int64, userID int64) error {
 	query := `
 		DELETE FROM portfolios
-		WHERE id = $1 AND user_id = $2
+		WHERE id = $1 AND EXISTS(
+			SELECT 1 FROM portfolio_members
+			WHERE portfolio_id = $1 AND user_id = $2 AND role = 'owner'
+		)
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
@@ -413,7 +540,7 @@ func (ps *PortfolioStore) AddStockToPortfolio(ctx context.Context, portfolioID i
 		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
 		defer cancel()
 
-		exist, err := ps.checkPortfolioExist(ctx, tx, portfolioID, userID)
+		exist, err := checkPortfolioExist(ctx, tx, portfolioID, userID)
 		if err != nil {
 			return err
 		}
@@ -421,56 +548,35 @@ func (ps *PortfolioStore) AddStockToPortfolio(ctx context.Context, portfolioID i
 			return ErrNotFound
 		}
 
-		stockQuery := `
-			INSERT INTO portfolio_stocks (portfolio_id, symbol, shares, average_price)
-			VALUES ($1, $2, $3, $4)
-			RETURNING id, created_at, updated_at
-		`
-
-		err = tx.QueryRowContext(
-			ctx,
-			stockQuery,
-			stock.PortfolioID,
-			stock.Symbol,
-			stock.Shares,
-			stock.AveragePrice,
-		).Scan(
-			&stock.ID,
-			&stock.CreatedAt,
-			&stock.UpdatedAt,
-		)
-
-		if err != nil {
-			var pqErr *pq.Error
-
-			if errors.As(err, &pqErr) && pqErr.Code == "23505" {
-				return ErrDuplicateStock
-			}
+		_, err = getPosition(ctx, tx, portfolioID, stock.Symbol)
+		if err == nil {
+			return ErrDuplicateStock
+		}
+		if !errors.Is(err, ErrNotFound) {
 			return err
 		}
 
-		portfolioQuery := `
-			UPDATE portfolios
-			SET updated_at = NOW()
-			WHERE id = $1
-		`
-
-		_, err = tx.ExecContext(ctx, portfolioQuery, portfolioID)
+		position, err := openPosition(ctx, tx, portfolioID, stock)
 		if err != nil {
 			return err
 		}
-		return nil
+		*stock = *position
+
+		return touchPortfolio(ctx, tx, portfolioID)
 	})
 
 }
 
+// UpdateStockToPortfolio records the difference between the current and the
+// requested share count as a buy or sell at the given price, then returns the
+// position derived from the ledger.
 func (ps *PortfolioStore) UpdateStockToPortfolio(ctx context.Context, portfolioID int64, userID int64, stock *Stock) (*Stock, error) {
 
-	var updatedStock Stock
+	var updatedStock *Stock
 	err := withTX(ps.db, ctx, func(tx *sql.Tx) error {
 		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
 		defer cancel()
-		exist, err := ps.checkPortfolioExist(ctx, tx, portfolioID, userID)
+		exist, err := checkPortfolioExist(ctx, tx, portfolioID, userID)
 		if err != nil {
 			return err
 		}
@@ -478,62 +584,61 @@ func (ps *PortfolioStore) UpdateStockToPortfolio(ctx context.Context, portfolioI
 			return ErrNotFound
 		}
 
-		stockQuery := `
-			UPDATE portfolio_stocks
-			SET shares = $1, average_price = $2, updated_at = NOW()
-			WHERE portfolio_id = $3 AND symbol = $4
-			RETURNING id, portfolio_id, symbol, shares, average_price, created_at, updated_at
-		`
+		current, err := getPosition(ctx, tx, portfolioID, stock.Symbol)
+		if err != nil {
+			return err
+		}
 
-		err = tx.QueryRowContext(
-			ctx,
-			stockQuery,
-			stock.Shares,
-			stock.AveragePrice,
-			stock.PortfolioID,
-			stock.Symbol,
-		).Scan(
-			&updatedStock.ID,
-			&updatedStock.PortfolioID,
-			&updatedStock.Symbol,
-			&updatedStock.Shares,
-			&updatedStock.AveragePrice,
-			&updatedStock.CreatedAt,
-			&updatedStock.UpdatedAt,
-		)
+		delta := stock.Shares - current.Shares
+		if math.Abs(delta) < shareEpsilon {
+			updatedStock = current
+			return nil
+		}
 
+		transaction := &Transaction{
+			PortfolioID: portfolioID,
+			Symbol:      stock.Symbol,
+			Type:        TransactionBuy,
+			TradeDate:   time.Now(),
+			Quantity:    delta,
+			Price:       stock.AveragePrice,
+			Currency:    stock.Currency,
+		}
+		if delta < 0 {
+			transaction.Type = TransactionSell
+			transaction.Quantity = -delta
+		}
+
+		err = insertTransaction(ctx, tx, transaction)
 		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				return ErrNotFound
-			}
 			return err
 		}
 
-		portfolioQuery := `
-			UPDATE portfolios 
-			SET updated_at = NOW()
-			WHERE id = $1
-		`
-		_, err = tx.ExecContext(ctx, portfolioQuery, portfolioID)
+		updatedStock, err = rebuildPosition(ctx, tx, portfolioID, stock.Symbol)
 		if err != nil {
 			return err
 		}
-		return nil
+		if updatedStock == nil {
+			return ErrInsufficientShares
+		}
+
+		return touchPortfolio(ctx, tx, portfolioID)
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &updatedStock, nil
+	return updatedStock, nil
 }
 
+// DeleteStockFromPortfolio removes the position together with its ledger.
 func (ps *PortfolioStore) DeleteStockFromPortfolio(ctx context.Context, portfolioID int64, userID int64, symbol string) error {
 
 	return withTX(ps.db, ctx, func(tx *sql.Tx) error {
 		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
 		defer cancel()
-		exist, err := ps.checkPortfolioExist(ctx, tx, portfolioID, userID)
+		exist, err := checkPortfolioExist(ctx, tx, portfolioID, userID)
 		if err != nil {
 			return err
 		}
@@ -558,22 +663,98 @@ func (ps *PortfolioStore) DeleteStockFromPortfolio(ctx context.Context, portfoli
 			return ErrNotFound
 		}
 
-		portfolioQuery := `
-			UPDATE portfolios 
-			SET updated_at = NOW()
-			WHERE id = $1
+		transactionQuery := `
+			DELETE FROM portfolio_transactions
+			WHERE portfolio_id = $1 AND symbol = $2
 		`
-		_, err = tx.ExecContext(ctx, portfolioQuery, portfolioID)
+		_, err = tx.ExecContext(ctx, transactionQuery, portfolioID, symbol)
 		if err != nil {
 			return err
 		}
-		return nil
+
+		return touchPortfolio(ctx, tx, portfolioID)
 	})
 }
 
-func (ps *PortfolioStore) checkPortfolioExist(ctx context.Context, tx *sql.Tx, portfolioID int64, userID int64) (bool, error) {
+// openPosition records the opening buy for a new holding and returns the derived position.
+func openPosition(ctx context.Context, tx *sql.Tx, portfolioID int64, stock *Stock) (*Stock, error) {
+	transaction := &Transaction{
+		PortfolioID: portfolioID,
+		Symbol:      stock.Symbol,
+		Type:        TransactionBuy,
+		TradeDate:   time.Now(),
+		Quantity:    stock.Shares,
+		Price:       stock.AveragePrice,
+		Currency:    stock.Currency,
+	}
+
+	err := insertTransaction(ctx, tx, transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	position, err := rebuildPosition(ctx, tx, portfolioID, stock.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	if position == nil {
+		return nil, ErrInsufficientShares
+	}
+
+	return position, nil
+}
+
+func getPosition(ctx context.Context, tx *sql.Tx, portfolioID int64, symbol string) (*Stock, error) {
+	query := `
+		SELECT id, portfolio_id, symbol, shares, average_price, currency, created_at, updated_at
+		FROM portfolio_stocks
+		WHERE portfolio_id = $1 AND symbol = $2
+	`
+
+	var stock Stock
+	err := tx.QueryRowContext(ctx, query, portfolioID, symbol).Scan(
+		&stock.ID,
+		&stock.PortfolioID,
+		&stock.Symbol,
+		&stock.Shares,
+		&stock.AveragePrice,
+		&stock.Currency,
+		&stock.CreatedAt,
+		&stock.UpdatedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &stock, nil
+}
+
+func touchPortfolio(ctx context.Context, tx *sql.Tx, portfolioID int64) error {
+	portfolioQuery := `
+		UPDATE portfolios 
+		SET updated_at = NOW()
+		WHERE id = $1
+	`
+	_, err := tx.ExecContext(ctx, portfolioQuery, portfolioID)
+	return err
+}
+
+// checkPortfolioExist reports whether userID may modify the portfolio, which
+// takes an editor or owner membership.
+func checkPortfolioExist(ctx context.Context, tx *sql.Tx, portfolioID int64, userID int64) (bool, error) {
 	var exists bool
-	checkQuery := `SELECT EXISTS(SELECT 1 FROM portfolios WHERE id = $1 AND user_id = $2)`
+	checkQuery := `
+		SELECT EXISTS(
+			SELECT 1 FROM portfolio_members
+			WHERE portfolio_id = $1 AND user_id = $2 AND role IN ('editor', 'owner')
+		)
+	`
 	err := tx.QueryRowContext(ctx, checkQuery, portfolioID, userID).Scan(&exists)
 	if err != nil {
 		return false, err
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrTokenReused = errors.New("refresh token reused")
+
+type RefreshToken struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	FamilyID  string     `json:"family_id"`
+	Expiry    time.Time  `json:"expiry"`
+	UsedAt    *time.Time `json:"used_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt string     `json:"created_at"`
+}
+
+type RefreshTokenStore struct {
+	db *sql.DB
+}
+
+// Rotate exchanges a valid refresh token for a new one in the same family.
+// Presenting a token that was already rotated means it leaked, so its session
+// is revoked and ErrTokenReused is returned along with the reused token.
+// Unknown, expired and revoked tokens are ErrNotFound.
+func (rs *RefreshTokenStore) Rotate(ctx context.Context, token string, newToken string, refreshEXP time.Duration) (*RefreshToken, error) {
+	var (
+		current *RefreshToken
+		rotated *RefreshToken
+		reused  bool
+	)
+
+	err := withTX(rs.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+		defer cancel()
+
+		query := `
+			SELECT id, user_id, family_id, expiry, used_at, revoked_at, created_at
+			FROM refresh_tokens
+			WHERE token = $1
+			FOR UPDATE
+		`
+
+		current = &RefreshToken{}
+		err := tx.QueryRowContext(ctx, query, token).Scan(
+			&current.ID,
+			&current.UserID,
+			&current.FamilyID,
+			&current.Expiry,
+			&current.UsedAt,
+			&current.RevokedAt,
+			&current.CreatedAt,
+		)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if current.RevokedAt != nil || current.Expiry.Before(time.Now()) {
+			return ErrNotFound
+		}
+
+		// Commit the revocation before reporting the reuse
+		if current.UsedAt != nil {
+			reused = true
+			return revokeSession(ctx, tx, current.FamilyID)
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1`, current.ID)
+		if err != nil {
+			return err
+		}
+
+		rotated = &RefreshToken{
+			UserID:   current.UserID,
+			FamilyID: current.FamilyID,
+			Expiry:   time.Now().Add(refreshEXP),
+		}
+
+		err = touchSession(ctx, tx, rotated.FamilyID, rotated.Expiry)
+		if err != nil {
+			return err
+		}
+
+		return insertRefreshToken(ctx, tx, rotated, newToken)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if reused {
+		return current, ErrTokenReused
+	}
+
+	return rotated, nil
+}
+
+func insertRefreshToken(ctx context.Context, tx *sql.Tx, refreshToken *RefreshToken, token string) error {
+	query := `
+		INSERT INTO refresh_tokens (token, user_id, family_id, expiry)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	return tx.QueryRowContext(ctx, query, token, refreshToken.UserID, refreshToken.FamilyID, refreshToken.Expiry).Scan(
+		&refreshToken.ID,
+		&refreshToken.CreatedAt,
+	)
+}
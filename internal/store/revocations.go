@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// RevocationStore lists access token and session IDs that must be rejected
+// before their tokens expire. It is used when Redis is not enabled.
+type RevocationStore struct {
+	db *sql.DB
+}
+
+func (rs *RevocationStore) Revoke(ctx context.Context, id string, expiry time.Time) error {
+	query := `
+		INSERT INTO revoked_tokens (id, expiry)
+		VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE
+		SET expiry = GREATEST(revoked_tokens.expiry, EXCLUDED.expiry)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	_, err := rs.db.ExecContext(ctx, query, id, expiry)
+	if err != nil {
+		return err
+	}
+
+	// Entries are useless once the tokens they block have expired
+	_, err = rs.db.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expiry < NOW()`)
+	return err
+}
+
+func (rs *RevocationStore) IsRevoked(ctx context.Context, id string) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE id = $1 AND expiry > NOW())`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	var revoked bool
+	err := rs.db.QueryRowContext(ctx, query, id).Scan(&revoked)
+	if err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
@@ -0,0 +1,244 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+type Security struct {
+	Symbol     string `json:"symbol"`
+	Name       string `json:"name"`
+	Exchange   string `json:"exchange"`
+	AssetClass string `json:"asset_class"`
+	Currency   string `json:"currency"`
+	Sector     string `json:"sector"`
+	Country    string `json:"country"`
+	UpdatedAt  string `json:"updated_at"`
+}
+
+type SecurityStore struct {
+	db *sql.DB
+}
+
+func (ss *SecurityStore) Upsert(ctx context.Context, securities []Security) error {
+	if len(securities) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO securities (symbol, name, exchange, asset_class, currency, sector, country)
+		SELECT * FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[], $6::text[], $7::text[])
+		ON CONFLICT (symbol) DO UPDATE
+		SET name = EXCLUDED.name, exchange = EXCLUDED.exchange, asset_class = EXCLUDED.asset_class,
+			currency = EXCLUDED.currency, sector = EXCLUDED.sector, country = EXCLUDED.country, updated_at = NOW()
+	`
+
+	n := len(securities)
+	symbols := make([]string, n)
+	names := make([]string, n)
+	exchanges := make([]string, n)
+	assetClasses := make([]string, n)
+	currencies := make([]string, n)
+	sectors := make([]string, n)
+	countries := make([]string, n)
+
+	for i, s := range securities {
+		symbols[i] = s.Symbol
+		names[i] = s.Name
+		exchanges[i] = s.Exchange
+		assetClasses[i] = s.AssetClass
+		currencies[i] = s.Currency
+		sectors[i] = s.Sector
+		countries[i] = s.Country
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	_, err := ss.db.ExecContext(
+		ctx,
+		query,
+		pq.Array(symbols),
+		pq.Array(names),
+		pq.Array(exchanges),
+		pq.Array(assetClasses),
+		pq.Array(currencies),
+		pq.Array(sectors),
+		pq.Array(countries),
+	)
+
+	return err
+}
+
+func (ss *SecurityStore) GetBySymbol(ctx context.Context, symbol string) (*Security, error) {
+	query := `
+		SELECT symbol, name, exchange, asset_class, currency, sector, country, updated_at
+		FROM securities
+		WHERE symbol = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	var s Security
+	err := ss.db.QueryRowContext(ctx, query, symbol).Scan(
+		&s.Symbol,
+		&s.Name,
+		&s.Exchange,
+		&s.AssetClass,
+		&s.Currency,
+		&s.Sector,
+		&s.Country,
+		&s.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &s, nil
+}
+
+// Search matches q against symbol and name for autocomplete: exact symbols
+// first, then symbol prefixes, then name prefixes, then names containing q.
+func (ss *SecurityStore) Search(ctx context.Context, q string, limit int) ([]Security, error) {
+	query := `
+		SELECT symbol, name, exchange, asset_class, currency, sector, country, updated_at
+		FROM securities
+		WHERE symbol LIKE $1 || '%' OR lower(name) LIKE '%' || $2 || '%'
+		ORDER BY
+			CASE
+				WHEN symbol = $1 THEN 0
+				WHEN symbol LIKE $1 || '%' THEN 1
+				WHEN lower(name) LIKE $2 || '%' THEN 2
+				ELSE 3
+			END,
+			length(symbol), symbol
+		LIMIT $3
+	`
+
+	// LIKE wildcards in the input are matched literally
+	escape := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	q = escape.Replace(strings.TrimSpace(q))
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	rows, err := ss.db.QueryContext(ctx, query, strings.ToUpper(q), strings.ToLower(q), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	securities := []Security{}
+	for rows.Next() {
+		var s Security
+
+		err := rows.Scan(
+			&s.Symbol,
+			&s.Name,
+			&s.Exchange,
+			&s.AssetClass,
+			&s.Currency,
+			&s.Sector,
+			&s.Country,
+			&s.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		securities = append(securities, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return securities, nil
+}
+
+// GetBySymbols returns the known securities among symbols, keyed by symbol.
+func (ss *SecurityStore) GetBySymbols(ctx context.Context, symbols []string) (map[string]Security, error) {
+	query := `
+		SELECT symbol, name, exchange, asset_class, currency, sector, country, updated_at
+		FROM securities
+		WHERE symbol = ANY($1)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	rows, err := ss.db.QueryContext(ctx, query, pq.Array(symbols))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	securities := make(map[string]Security, len(symbols))
+	for rows.Next() {
+		var s Security
+
+		err := rows.Scan(
+			&s.Symbol,
+			&s.Name,
+			&s.Exchange,
+			&s.AssetClass,
+			&s.Currency,
+			&s.Sector,
+			&s.Country,
+			&s.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		securities[s.Symbol] = s
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return securities, nil
+}
+
+// GetUnknown returns the symbols that are not in the reference data.
+func (ss *SecurityStore) GetUnknown(ctx context.Context, symbols []string) ([]string, error) {
+	query := `
+		SELECT DISTINCT s.symbol
+		FROM unnest($1::text[]) AS s(symbol)
+		WHERE NOT EXISTS (SELECT 1 FROM securities WHERE securities.symbol = s.symbol)
+		ORDER BY s.symbol
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	rows, err := ss.db.QueryContext(ctx, query, pq.Array(symbols))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	unknown := []string{}
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err != nil {
+			return nil, err
+		}
+		unknown = append(unknown, symbol)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return unknown, nil
+}
@@ -0,0 +1,218 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Session is one signed in device. Its ID is the family of the refresh
+// tokens rotated from the sign in and the sid claim of its access tokens.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int64     `json:"user_id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	Expiry     time.Time `json:"expiry"`
+	CreatedAt  string    `json:"created_at"`
+	LastUsedAt string    `json:"last_used_at"`
+	Current    bool      `json:"current"`
+}
+
+type SessionStore struct {
+	db *sql.DB
+}
+
+// Create starts a session with its first refresh token.
+func (ss *SessionStore) Create(ctx context.Context, session *Session, refreshToken *RefreshToken, token string) error {
+	return withTX(ss.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO sessions (id, user_id, user_agent, ip_address, expiry)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING created_at, last_used_at
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, session.ID, session.UserID, session.UserAgent, session.IPAddress, session.Expiry).Scan(
+			&session.CreatedAt,
+			&session.LastUsedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		refreshToken.UserID = session.UserID
+		refreshToken.FamilyID = session.ID
+
+		return insertRefreshToken(ctx, tx, refreshToken, token)
+	})
+}
+
+// GetByUser lists the sessions that are neither revoked nor expired, most
+// recently used first.
+func (ss *SessionStore) GetByUser(ctx context.Context, userID int64) ([]Session, error) {
+	query := `
+		SELECT id, user_id, user_agent, ip_address, expiry, created_at, last_used_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expiry > NOW()
+		ORDER BY last_used_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	rows, err := ss.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var s Session
+
+		err := rows.Scan(
+			&s.ID,
+			&s.UserID,
+			&s.UserAgent,
+			&s.IPAddress,
+			&s.Expiry,
+			&s.CreatedAt,
+			&s.LastUsedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Revoke ends one of the user's sessions and its refresh tokens.
+func (ss *SessionStore) Revoke(ctx context.Context, sessionID string, userID int64) error {
+	return withTX(ss.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+		defer cancel()
+
+		var exists bool
+		checkQuery := `SELECT EXISTS(SELECT 1 FROM sessions WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL)`
+		err := tx.QueryRowContext(ctx, checkQuery, sessionID, userID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNotFound
+		}
+
+		return revokeSession(ctx, tx, sessionID)
+	})
+}
+
+// RevokeAll ends every session of the user and returns their IDs.
+func (ss *SessionStore) RevokeAll(ctx context.Context, userID int64) ([]string, error) {
+	var sessionIDs []string
+
+	err := withTX(ss.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+		defer cancel()
+
+		ids, err := revokeUserSessions(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		sessionIDs = ids
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sessionIDs, nil
+}
+
+func revokeUserSessions(ctx context.Context, tx *sql.Tx, userID int64) ([]string, error) {
+	query := `
+		UPDATE sessions
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+		RETURNING id
+	`
+
+	rows, err := tx.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionIDs := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		sessionIDs = append(sessionIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	refreshQuery := `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`
+	_, err = tx.ExecContext(ctx, refreshQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return sessionIDs, nil
+}
+
+func revokeSession(ctx context.Context, tx *sql.Tx, sessionID string) error {
+	_, err := tx.ExecContext(ctx, `UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`, sessionID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`
+
+	_, err = tx.ExecContext(ctx, query, sessionID)
+	return err
+}
+
+func touchSession(ctx context.Context, tx *sql.Tx, sessionID string, expiry time.Time) error {
+	query := `
+		UPDATE sessions
+		SET last_used_at = NOW(), expiry = $2
+		WHERE id = $1
+	`
+
+	result, err := tx.ExecContext(ctx, query, sessionID, expiry)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type Snapshot struct {
+	PortfolioID int64     `json:"portfolio_id"`
+	Date        time.Time `json:"date"`
+	Currency    string    `json:"currency"`
+	MarketValue float64   `json:"market_value"`
+	CostBasis   float64   `json:"cost_basis"`
+	Cash        float64   `json:"cash"`
+	TotalValue  float64   `json:"total_value"`
+	// Holdings left out of the values because they had no close or no rate
+	// on the date; a snapshot with either is incomplete.
+	UnpricedSymbols       []string `json:"unpriced_symbols"`
+	UnconvertedCurrencies []string `json:"unconverted_currencies"`
+}
+
+type SnapshotStore struct {
+	db *sql.DB
+}
+
+// snapshotChunkSize caps the rows written per statement so a long backfill
+// never runs into QueryTimeOut.
+const snapshotChunkSize = 500
+
+// Upsert stores end of day values, replacing any snapshot already taken for
+// the same portfolio and date. Rows are written in chunks, each committed on
+// its own; a failed chunk leaves the earlier ones stored.
+func (ss *SnapshotStore) Upsert(ctx context.Context, snapshots []Snapshot) error {
+	for len(snapshots) > 0 {
+		n := min(len(snapshots), snapshotChunkSize)
+
+		err := ss.upsert(ctx, snapshots[:n])
+		if err != nil {
+			return err
+		}
+		snapshots = snapshots[n:]
+	}
+
+	return nil
+}
+
+func (ss *SnapshotStore) upsert(ctx context.Context, snapshots []Snapshot) error {
+	query := `
+		INSERT INTO portfolio_snapshots (portfolio_id, snapshot_date, currency, market_value, cost_basis, cash, total_value, unpriced_symbols, unconverted_currencies)
+		SELECT t.portfolio_id, t.snapshot_date::date, t.currency, t.market_value, t.cost_basis, t.cash, t.total_value,
+			string_to_array(t.unpriced_symbols, ','), string_to_array(t.unconverted_currencies, ',')
+		FROM unnest($1::bigint[], $2::text[], $3::text[], $4::numeric[], $5::numeric[], $6::numeric[], $7::numeric[], $8::text[], $9::text[])
+			AS t(portfolio_id, snapshot_date, currency, market_value, cost_basis, cash, total_value, unpriced_symbols, unconverted_currencies)
+		ON CONFLICT (portfolio_id, snapshot_date) DO UPDATE
+		SET currency = EXCLUDED.currency,
+			market_value = EXCLUDED.market_value,
+			cost_basis = EXCLUDED.cost_basis,
+			cash = EXCLUDED.cash,
+			total_value = EXCLUDED.total_value,
+			unpriced_symbols = EXCLUDED.unpriced_symbols,
+			unconverted_currencies = EXCLUDED.unconverted_currencies,
+			created_at = NOW()
+	`
+
+	n := len(snapshots)
+	portfolioIDs := make([]int64, n)
+	dates := make([]string, n)
+	currencies := make([]string, n)
+	marketValues := make([]float64, n)
+	costBases := make([]float64, n)
+	cash := make([]float64, n)
+	totalValues := make([]float64, n)
+	// Symbols and currencies never contain a comma, so each row's list is
+	// passed joined and split again in the statement
+	unpriced := make([]string, n)
+	unconverted := make([]string, n)
+
+	for i, s := range snapshots {
+		portfolioIDs[i] = s.PortfolioID
+		dates[i] = s.Date.Format(DateLayout)
+		currencies[i] = s.Currency
+		marketValues[i] = s.MarketValue
+		costBases[i] = s.CostBasis
+		cash[i] = s.Cash
+		totalValues[i] = s.TotalValue
+		unpriced[i] = strings.Join(s.UnpricedSymbols, ",")
+		unconverted[i] = strings.Join(s.UnconvertedCurrencies, ",")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	_, err := ss.db.ExecContext(
+		ctx,
+		query,
+		pq.Array(portfolioIDs),
+		pq.Array(dates),
+		pq.Array(currencies),
+		pq.Array(marketValues),
+		pq.Array(costBases),
+		pq.Array(cash),
+		pq.Array(totalValues),
+		pq.Array(unpriced),
+		pq.Array(unconverted),
+	)
+
+	return err
+}
+
+func (ss *SnapshotStore) GetRange(ctx context.Context, portfolioID int64, from, to time.Time) ([]Snapshot, error) {
+	query := `
+		SELECT portfolio_id, snapshot_date, currency, market_value, cost_basis, cash, total_value, unpriced_symbols, unconverted_currencies
+		FROM portfolio_snapshots
+		WHERE portfolio_id = $1 AND snapshot_date BETWEEN $2 AND $3
+		ORDER BY snapshot_date ASC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	rows, err := ss.db.QueryContext(ctx, query, portfolioID, from.Format(DateLayout), to.Format(DateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []Snapshot{}
+	for rows.Next() {
+		var s Snapshot
+
+		err := rows.Scan(
+			&s.PortfolioID,
+			&s.Date,
+			&s.Currency,
+			&s.MarketValue,
+			&s.CostBasis,
+			&s.Cash,
+			&s.TotalValue,
+			pq.Array(&s.UnpricedSymbols),
+			pq.Array(&s.UnconvertedCurrencies),
+		)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
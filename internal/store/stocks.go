@@ -1,7 +1,12 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 type Stock struct {
@@ -10,6 +15,7 @@ type Stock struct {
 	Symbol       string  `json:"symbol"`
 	Shares       float64 `json:"shares"`
 	AveragePrice float64 `json:"average_price"`
+	Currency     string  `json:"currency"`
 	CreatedAt    string  `json:"created_at"`
 	UpdatedAt    string  `json:"updated_at"`
 }
@@ -17,3 +23,138 @@ type Stock struct {
 type StockStore struct {
 	db *sql.DB
 }
+
+func (ss *StockStore) UpsertHistory(ctx context.Context, history *StockHistory) error {
+	if len(history.Data) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO stock_prices (symbol, date, open, high, low, close, volume)
+		SELECT $1, t.date::date, t.open, t.high, t.low, t.close, t.volume
+		FROM unnest($2::text[], $3::numeric[], $4::numeric[], $5::numeric[], $6::numeric[], $7::bigint[])
+			AS t(date, open, high, low, close, volume)
+		ON CONFLICT (symbol, date) DO UPDATE
+		SET open = EXCLUDED.open,
+			high = EXCLUDED.high,
+			low = EXCLUDED.low,
+			close = EXCLUDED.close,
+			volume = EXCLUDED.volume,
+			updated_at = NOW()
+	`
+
+	n := len(history.Data)
+	dates := make([]string, n)
+	opens := make([]float64, n)
+	highs := make([]float64, n)
+	lows := make([]float64, n)
+	closes := make([]float64, n)
+	volumes := make([]int64, n)
+
+	for i, d := range history.Data {
+		dates[i] = d.Date.Format(DateLayout)
+		opens[i] = d.Open
+		highs[i] = d.High
+		lows[i] = d.Low
+		closes[i] = d.Close
+		volumes[i] = d.Volume
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	_, err := ss.db.ExecContext(
+		ctx,
+		query,
+		history.Symbol,
+		pq.Array(dates),
+		pq.Array(opens),
+		pq.Array(highs),
+		pq.Array(lows),
+		pq.Array(closes),
+		pq.Array(volumes),
+	)
+
+	return err
+}
+
+func (ss *StockStore) GetHistory(ctx context.Context, symbol string, from, to time.Time) (*StockHistory, error) {
+	query := `
+		SELECT date, open, high, low, close, volume
+		FROM stock_prices
+		WHERE symbol = $1 AND date BETWEEN $2 AND $3
+		ORDER BY date ASC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	rows, err := ss.db.QueryContext(ctx, query, symbol, from.Format(DateLayout), to.Format(DateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := &StockHistory{
+		Symbol: symbol,
+		Data:   []DailyData{},
+	}
+
+	for rows.Next() {
+		var d DailyData
+
+		err := rows.Scan(
+			&d.Date,
+			&d.Open,
+			&d.High,
+			&d.Low,
+			&d.Close,
+			&d.Volume,
+		)
+		if err != nil {
+			return nil, err
+		}
+		history.Data = append(history.Data, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+func (ss *StockStore) GetLatestPrice(ctx context.Context, symbol string) (*DailyData, error) {
+	query := `
+		SELECT date, open, high, low, close, volume
+		FROM stock_prices
+		WHERE symbol = $1
+		ORDER BY date DESC
+		LIMIT 1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	var d DailyData
+
+	err := ss.db.QueryRowContext(ctx, query, symbol).Scan(
+		&d.Date,
+		&d.Open,
+		&d.High,
+		&d.Low,
+		&d.Close,
+		&d.Volume,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &d, nil
+}
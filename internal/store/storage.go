@@ -13,8 +13,12 @@ var (
 	ErrDuplicateEmail    = errors.New("duplicate email")
 	ErrDuplicateUsername = errors.New("duplicate username")
 	ErrDuplicateStock    = errors.New("stock already exists in portfolio")
+	ErrDuplicateAction   = errors.New("corporate action already recorded")
+	ErrUnknownSymbol     = errors.New("unknown symbol")
 
 	QueryTimeOut = time.Second * 5
+	// ImportTimeOut stays under the API's 30s write timeout
+	ImportTimeOut = time.Second * 25
 )
 
 type Storage struct {
@@ -25,11 +29,50 @@ type Storage struct {
 		GetUserByID(context.Context, int64) (*User, error)
 		GetByEmail(context.Context, string) (*User, error)
 		DeleteUser(context.Context, int64) error
+		CreatePasswordReset(context.Context, int64, string, time.Duration) error
+		ResetPassword(context.Context, string, *User) ([]string, error)
+
+		//administration
+		List(context.Context, *UserFilterQuery) ([]*User, error)
+		GetAccount(context.Context, int64) (*User, error)
+		Deactivate(context.Context, int64) ([]string, error)
+		Reactivate(context.Context, int64) error
+		Reinvite(context.Context, int64, string, time.Duration) error
+		SetRole(context.Context, int64, string) error
+	}
+	RefreshTokens interface {
+		Rotate(context.Context, string, string, time.Duration) (*RefreshToken, error)
+	}
+	Sessions interface {
+		Create(context.Context, *Session, *RefreshToken, string) error
+		GetByUser(context.Context, int64) ([]Session, error)
+		Revoke(context.Context, string, int64) error
+		RevokeAll(context.Context, int64) ([]string, error)
+	}
+	Revocations interface {
+		Revoke(context.Context, string, time.Time) error
+		IsRevoked(context.Context, string) (bool, error)
+	}
+	Attempts interface {
+		Increment(context.Context, string, time.Time) (int64, error)
+		Get(context.Context, string) (int64, error)
+		Reset(context.Context, string) error
+	}
+	MFA interface {
+		Get(context.Context, int64) (*MFA, error)
+		Enroll(context.Context, int64, string) error
+		Enable(context.Context, int64, int64, []string) error
+		UseStep(context.Context, int64, int64) error
+		UseRecoveryCode(context.Context, int64, string) error
+		RegenerateRecoveryCodes(context.Context, int64, []string) error
+		Disable(context.Context, int64) error
 	}
 	Portfolio interface {
 		Create(context.Context, *sql.Tx, *Portfolio) error
 		CreatePortfolioWithStocks(context.Context, *Portfolio) error
 		GetPortfolios(context.Context, int64, *PaginatedFeedQuery) ([]*Portfolio, error)
+		GetPortfolioIDs(context.Context, int64, string) ([]int64, error)
+		GetAll(context.Context) ([]*Portfolio, error)
 		SearchPortfoliosByName(context.Context, int64, string) ([]*Portfolio, error)
 		GetPortfolioByID(context.Context, int64, int64) (*Portfolio, error)
 		UpdatePortfolio(context.Context, *Portfolio, int64) (*Portfolio, error)
@@ -40,15 +83,96 @@ type Storage struct {
 		UpdateStockToPortfolio(context.Context, int64, int64, *Stock) (*Stock, error)
 		DeleteStockFromPortfolio(context.Context, int64, int64, string) error
 	}
+	Members interface {
+		GetByPortfolio(context.Context, int64) ([]Member, error)
+		GetRole(context.Context, int64, int64) (string, error)
+		Invite(context.Context, *PortfolioInvitation, string, time.Duration) error
+		DeleteInvitation(context.Context, string) error
+		Accept(context.Context, string, *User) (*Member, error)
+		UpdateRole(context.Context, int64, int64, string) error
+		Remove(context.Context, int64, int64) error
+	}
 	Stocks interface {
+		UpsertHistory(context.Context, *StockHistory) error
+		GetHistory(context.Context, string, time.Time, time.Time) (*StockHistory, error)
+		GetLatestPrice(context.Context, string) (*DailyData, error)
+	}
+	Transactions interface {
+		Record(context.Context, int64, int64, *Transaction) (*Stock, error)
+		Import(context.Context, int64, int64, []*Transaction) error
+		GetByPortfolio(context.Context, int64, string) ([]Transaction, error)
+	}
+	Cash interface {
+		Record(context.Context, int64, int64, *CashEntry) error
+		GetEntries(context.Context, int64, string) ([]CashEntry, error)
+		GetBalances(context.Context, int64) ([]CashBalance, error)
+	}
+	CorporateActions interface {
+		Create(context.Context, *CorporateAction) error
+		GetBySymbol(context.Context, string) ([]CorporateAction, error)
+	}
+	Targets interface {
+		Get(context.Context, int64) (*TargetAllocation, error)
+		Set(context.Context, int64, *TargetAllocation) error
+	}
+	Alerts interface {
+		Create(context.Context, *Alert) error
+		GetByUser(context.Context, int64) ([]Alert, error)
+		GetByID(context.Context, int64, int64) (*Alert, error)
+		GetActive(context.Context) ([]Alert, error)
+		Update(context.Context, *Alert) error
+		Delete(context.Context, int64, int64) error
+		Trigger(context.Context, int64, float64) (bool, error)
+		Rearm(context.Context, int64, float64) (bool, error)
+	}
+	Watchlists interface {
+		Create(context.Context, *Watchlist) error
+		GetByUser(context.Context, int64) ([]Watchlist, error)
+		GetByID(context.Context, int64, int64) (*Watchlist, error)
+		Update(context.Context, *Watchlist) error
+		Delete(context.Context, int64, int64) error
+		AddItem(context.Context, *WatchlistItem) error
+		UpdateItem(context.Context, *WatchlistItem) error
+		RemoveItem(context.Context, int64, string) error
+		Reorder(context.Context, int64, []string) error
+	}
+	Securities interface {
+		Upsert(context.Context, []Security) error
+		GetBySymbol(context.Context, string) (*Security, error)
+		GetBySymbols(context.Context, []string) (map[string]Security, error)
+		Search(context.Context, string, int) ([]Security, error)
+		GetUnknown(context.Context, []string) ([]string, error)
+	}
+	Snapshots interface {
+		Upsert(context.Context, []Snapshot) error
+		GetRange(context.Context, int64, time.Time, time.Time) ([]Snapshot, error)
+	}
+	FXRates interface {
+		UpsertRates(context.Context, []FXRate) error
+		GetRate(context.Context, string, string, time.Time) (*FXRate, error)
 	}
 }
 
 func NewStorage(db *sql.DB) *Storage {
 	return &Storage{
-		Users:     &UserStore{db},
-		Portfolio: &PortfolioStore{db},
-		Stocks:    &StockStore{db},
+		Users:            &UserStore{db},
+		RefreshTokens:    &RefreshTokenStore{db},
+		Sessions:         &SessionStore{db},
+		Revocations:      &RevocationStore{db},
+		Attempts:         &AttemptStore{db},
+		MFA:              &MFAStore{db},
+		Portfolio:        &PortfolioStore{db},
+		Members:          &MemberStore{db},
+		Stocks:           &StockStore{db},
+		Transactions:     &TransactionStore{db},
+		Cash:             &CashStore{db},
+		FXRates:          &FXStore{db},
+		CorporateActions: &CorporateActionStore{db},
+		Targets:          &TargetStore{db},
+		Alerts:           &AlertStore{db},
+		Watchlists:       &WatchlistStore{db},
+		Securities:       &SecurityStore{db},
+		Snapshots:        &SnapshotStore{db},
 	}
 }
 
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+type Target struct {
+	Symbol string  `json:"symbol"`
+	Weight float64 `json:"weight"`
+}
+
+// TargetAllocation holds a portfolio's target weights (fractions of 1) and the
+// settings used when rebalancing towards them.
+type TargetAllocation struct {
+	PortfolioID      int64    `json:"portfolio_id"`
+	FractionalShares bool     `json:"fractional_shares"`
+	DriftTolerance   float64  `json:"drift_tolerance"`
+	Targets          []Target `json:"targets"`
+}
+
+type TargetStore struct {
+	db *sql.DB
+}
+
+func (ts *TargetStore) Get(ctx context.Context, portfolioID int64) (*TargetAllocation, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	allocation := &TargetAllocation{
+		PortfolioID: portfolioID,
+		Targets:     []Target{},
+	}
+
+	err := ts.db.QueryRowContext(
+		ctx,
+		`SELECT fractional_shares, drift_tolerance FROM portfolios WHERE id = $1`,
+		portfolioID,
+	).Scan(
+		&allocation.FractionalShares,
+		&allocation.DriftTolerance,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	rows, err := ts.db.QueryContext(
+		ctx,
+		`SELECT symbol, weight FROM portfolio_targets WHERE portfolio_id = $1 ORDER BY weight DESC, symbol ASC`,
+		portfolioID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t Target
+		if err := rows.Scan(&t.Symbol, &t.Weight); err != nil {
+			return nil, err
+		}
+		allocation.Targets = append(allocation.Targets, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return allocation, nil
+}
+
+// Set replaces the portfolio's targets and rebalancing settings.
+func (ts *TargetStore) Set(ctx context.Context, userID int64, allocation *TargetAllocation) error {
+	return withTX(ts.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+		defer cancel()
+
+		exist, err := checkPortfolioExist(ctx, tx, allocation.PortfolioID, userID)
+		if err != nil {
+			return err
+		}
+		if !exist {
+			return ErrNotFound
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`UPDATE portfolios SET fractional_shares = $1, drift_tolerance = $2, updated_at = NOW() WHERE id = $3`,
+			allocation.FractionalShares,
+			allocation.DriftTolerance,
+			allocation.PortfolioID,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM portfolio_targets WHERE portfolio_id = $1`, allocation.PortfolioID)
+		if err != nil {
+			return err
+		}
+
+		for _, t := range allocation.Targets {
+			_, err = tx.ExecContext(
+				ctx,
+				`INSERT INTO portfolio_targets (portfolio_id, symbol, weight) VALUES ($1, $2, $3)`,
+				allocation.PortfolioID,
+				t.Symbol,
+				t.Weight,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,431 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	TransactionBuy      = "buy"
+	TransactionSell     = "sell"
+	TransactionSplit    = "split"
+	TransactionDividend = "dividend"
+
+	shareEpsilon = 1e-9
+)
+
+var (
+	ErrInsufficientShares = errors.New("not enough shares to sell")
+	ErrCurrencyMismatch   = errors.New("currency differs from the position's currency")
+)
+
+type Transaction struct {
+	ID          int64     `json:"id"`
+	PortfolioID int64     `json:"portfolio_id"`
+	Symbol      string    `json:"symbol"`
+	Type        string    `json:"type"`
+	TradeDate   time.Time `json:"trade_date"`
+	Quantity    float64   `json:"quantity"`
+	Price       float64   `json:"price"`
+	Fees        float64   `json:"fees"`
+	Currency    string    `json:"currency"`
+	CreatedAt   string    `json:"created_at"`
+}
+
+type TransactionStore struct {
+	db *sql.DB
+}
+
+// Record appends a transaction to the ledger and rebuilds the position it
+// affects. A dividend is also credited to cash.
+func (ts *TransactionStore) Record(ctx context.Context, portfolioID int64, userID int64, transaction *Transaction) (*Stock, error) {
+	var position *Stock
+
+	err := withTX(ts.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+		defer cancel()
+
+		exist, err := checkPortfolioExist(ctx, tx, portfolioID, userID)
+		if err != nil {
+			return err
+		}
+		if !exist {
+			return ErrNotFound
+		}
+
+		transaction.PortfolioID = portfolioID
+		err = insertTransaction(ctx, tx, transaction)
+		if err != nil {
+			return err
+		}
+
+		err = creditDividend(ctx, tx, transaction)
+		if err != nil {
+			return err
+		}
+
+		position, err = rebuildPosition(ctx, tx, portfolioID, transaction.Symbol)
+		if err != nil {
+			return err
+		}
+
+		return touchPortfolio(ctx, tx, portfolioID)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return position, nil
+}
+
+// Import records a batch of transactions all-or-nothing, credits dividends to
+// cash and rebuilds the positions of the symbols it touches. Large uploads get ImportTimeOut
+// rather than QueryTimeOut.
+func (ts *TransactionStore) Import(ctx context.Context, portfolioID int64, userID int64, transactions []*Transaction) error {
+	ctx, cancel := context.WithTimeout(ctx, ImportTimeOut)
+	defer cancel()
+
+	return withTX(ts.db, ctx, func(tx *sql.Tx) error {
+		exist, err := checkPortfolioExist(ctx, tx, portfolioID, userID)
+		if err != nil {
+			return err
+		}
+		if !exist {
+			return ErrNotFound
+		}
+
+		err = insertTransactions(ctx, tx, portfolioID, transactions)
+		if err != nil {
+			return err
+		}
+
+		seen := make(map[string]bool, len(transactions))
+		for _, transaction := range transactions {
+			err = creditDividend(ctx, tx, transaction)
+			if err != nil {
+				return err
+			}
+
+			if seen[transaction.Symbol] {
+				continue
+			}
+			seen[transaction.Symbol] = true
+
+			_, err = rebuildPosition(ctx, tx, portfolioID, transaction.Symbol)
+			if err != nil {
+				return err
+			}
+		}
+
+		return touchPortfolio(ctx, tx, portfolioID)
+	})
+}
+
+func (ts *TransactionStore) GetByPortfolio(ctx context.Context, portfolioID int64, symbol string) ([]Transaction, error) {
+	query := `
+		SELECT id, portfolio_id, symbol, type, trade_date, quantity, price, fees, currency, created_at
+		FROM portfolio_transactions
+		WHERE portfolio_id = $1 AND ($2 = '' OR symbol = $2)
+		ORDER BY trade_date ASC, id ASC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	rows, err := ts.db.QueryContext(ctx, query, portfolioID, symbol)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTransactions(rows)
+}
+
+// creditDividend adds a dividend's net amount to the cash ledger, as applying a
+// dividend corporate action does. Other transactions leave cash alone.
+func creditDividend(ctx context.Context, tx *sql.Tx, transaction *Transaction) error {
+	if transaction.Type != TransactionDividend {
+		return nil
+	}
+
+	return insertCashEntry(ctx, tx, &CashEntry{
+		PortfolioID: transaction.PortfolioID,
+		Currency:    transaction.Currency,
+		Type:        CashDividend,
+		Amount:      transaction.Quantity*transaction.Price - transaction.Fees,
+		EntryDate:   transaction.TradeDate,
+		Description: fmt.Sprintf("%s dividend %g x %g", transaction.Symbol, transaction.Quantity, transaction.Price),
+	})
+}
+
+func insertTransaction(ctx context.Context, tx *sql.Tx, transaction *Transaction) error {
+	return insertTransactions(ctx, tx, transaction.PortfolioID, []*Transaction{transaction})
+}
+
+// insertTransactions appends a batch to the ledger in one statement. A
+// symbol's trades stay in the currency its ledger was opened in: trades
+// without a currency take it, trades in another currency are rejected.
+// Dividends may be paid in any currency.
+func insertTransactions(ctx context.Context, tx *sql.Tx, portfolioID int64, transactions []*Transaction) error {
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	var baseCurrency string
+	currencies := map[string]string{}
+
+	for _, t := range transactions {
+		currency, ok := currencies[t.Symbol]
+		if !ok {
+			var err error
+			currency, err = ledgerCurrency(ctx, tx, portfolioID, t.Symbol)
+			if err != nil {
+				return err
+			}
+			if currency == "" && t.Type != TransactionDividend {
+				currency = t.Currency
+			}
+			if currency == "" {
+				if baseCurrency == "" {
+					err = tx.QueryRowContext(ctx, `SELECT base_currency FROM portfolios WHERE id = $1`, portfolioID).Scan(&baseCurrency)
+					if err != nil {
+						return err
+					}
+				}
+				currency = baseCurrency
+			}
+			currencies[t.Symbol] = currency
+		}
+
+		switch {
+		case t.Currency == "":
+			t.Currency = currency
+		case t.Currency != currency && t.Type != TransactionDividend:
+			return ErrCurrencyMismatch
+		}
+	}
+
+	// Rows are inserted, and so returned, in batch order
+	query := `
+		INSERT INTO portfolio_transactions (portfolio_id, symbol, type, trade_date, quantity, price, fees, currency)
+		SELECT $1, t.symbol, t.type, t.trade_date::date, t.quantity, t.price, t.fees, t.currency
+		FROM unnest($2::text[], $3::text[], $4::text[], $5::numeric[], $6::numeric[], $7::numeric[], $8::text[])
+			WITH ORDINALITY AS t(symbol, type, trade_date, quantity, price, fees, currency, n)
+		ORDER BY t.n
+		RETURNING id, created_at
+	`
+
+	n := len(transactions)
+	symbols := make([]string, n)
+	types := make([]string, n)
+	tradeDates := make([]string, n)
+	quantities := make([]float64, n)
+	prices := make([]float64, n)
+	fees := make([]float64, n)
+	currencyCodes := make([]string, n)
+
+	for i, t := range transactions {
+		t.PortfolioID = portfolioID
+		symbols[i] = t.Symbol
+		types[i] = t.Type
+		tradeDates[i] = t.TradeDate.Format(DateLayout)
+		quantities[i] = t.Quantity
+		prices[i] = t.Price
+		fees[i] = t.Fees
+		currencyCodes[i] = t.Currency
+	}
+
+	rows, err := tx.QueryContext(
+		ctx,
+		query,
+		portfolioID,
+		pq.Array(symbols),
+		pq.Array(types),
+		pq.Array(tradeDates),
+		pq.Array(quantities),
+		pq.Array(prices),
+		pq.Array(fees),
+		pq.Array(currencyCodes),
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	i := 0
+	for rows.Next() {
+		t := transactions[i]
+		err := rows.Scan(&t.ID, &t.CreatedAt)
+		if err != nil {
+			return err
+		}
+		i++
+	}
+
+	return rows.Err()
+}
+
+// ledgerCurrency returns the currency of the first trade recorded for the
+// symbol, or "" when the portfolio has never traded it.
+func ledgerCurrency(ctx context.Context, tx *sql.Tx, portfolioID int64, symbol string) (string, error) {
+	query := `
+		SELECT currency
+		FROM portfolio_transactions
+		WHERE portfolio_id = $1 AND symbol = $2 AND type <> 'dividend'
+		ORDER BY id ASC
+		LIMIT 1
+	`
+
+	var currency string
+	err := tx.QueryRowContext(ctx, query, portfolioID, symbol).Scan(&currency)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", nil
+		default:
+			return "", err
+		}
+	}
+
+	return currency, nil
+}
+
+// rebuildPositions derives every position of a portfolio from the ledger.
+func rebuildPositions(ctx context.Context, tx *sql.Tx, portfolioID int64) error {
+	rows, err := tx.QueryContext(ctx, `SELECT DISTINCT symbol FROM portfolio_transactions WHERE portfolio_id = $1`, portfolioID)
+	if err != nil {
+		return err
+	}
+
+	symbols := []string{}
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err != nil {
+			rows.Close()
+			return err
+		}
+		symbols = append(symbols, symbol)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, symbol := range symbols {
+		_, err := rebuildPosition(ctx, tx, portfolioID, symbol)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rebuildPosition derives a symbol's portfolio_stocks row from the ledger,
+// removing it once the position is closed. It returns nil for closed positions.
+func rebuildPosition(ctx context.Context, tx *sql.Tx, portfolioID int64, symbol string) (*Stock, error) {
+	transactions, err := getSymbolTransactions(ctx, tx, portfolioID, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	var method string
+	err = tx.QueryRowContext(ctx, `SELECT cost_basis_method FROM portfolios WHERE id = $1`, portfolioID).Scan(&method)
+	if err != nil {
+		return nil, err
+	}
+
+	shares, averagePrice, err := DerivePosition(transactions, method)
+	if err != nil {
+		return nil, err
+	}
+
+	if shares == 0 {
+		_, err = tx.ExecContext(ctx, `DELETE FROM portfolio_stocks WHERE portfolio_id = $1 AND symbol = $2`, portfolioID, symbol)
+		return nil, err
+	}
+
+	currency, err := ledgerCurrency(ctx, tx, portfolioID, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	upsertQuery := `
+		INSERT INTO portfolio_stocks (portfolio_id, symbol, shares, average_price, currency)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (portfolio_id, symbol) DO UPDATE
+		SET shares = EXCLUDED.shares, average_price = EXCLUDED.average_price, currency = EXCLUDED.currency, updated_at = NOW()
+		RETURNING id, portfolio_id, symbol, shares, average_price, currency, created_at, updated_at
+	`
+
+	var stock Stock
+	err = tx.QueryRowContext(ctx, upsertQuery, portfolioID, symbol, shares, averagePrice, currency).Scan(
+		&stock.ID,
+		&stock.PortfolioID,
+		&stock.Symbol,
+		&stock.Shares,
+		&stock.AveragePrice,
+		&stock.Currency,
+		&stock.CreatedAt,
+		&stock.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stock, nil
+}
+
+func getSymbolTransactions(ctx context.Context, tx *sql.Tx, portfolioID int64, symbol string) ([]Transaction, error) {
+	query := `
+		SELECT id, portfolio_id, symbol, type, trade_date, quantity, price, fees, currency, created_at
+		FROM portfolio_transactions
+		WHERE portfolio_id = $1 AND symbol = $2
+		ORDER BY trade_date ASC, id ASC
+	`
+
+	rows, err := tx.QueryContext(ctx, query, portfolioID, symbol)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTransactions(rows)
+}
+
+func scanTransactions(rows *sql.Rows) ([]Transaction, error) {
+	transactions := []Transaction{}
+
+	for rows.Next() {
+		var t Transaction
+
+		err := rows.Scan(
+			&t.ID,
+			&t.PortfolioID,
+			&t.Symbol,
+			&t.Type,
+			&t.TradeDate,
+			&t.Quantity,
+			&t.Price,
+			&t.Fees,
+			&t.Currency,
+			&t.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
@@ -12,16 +12,48 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	UserRoleUser    = "user"
+	UserRoleSupport = "support"
+	UserRoleAdmin   = "admin"
+
+	UserStatusActive      = "active"
+	UserStatusPending     = "pending"
+	UserStatusDeactivated = "deactivated"
+)
+
+var (
+	ErrNotPending = errors.New("user is not pending activation")
+
+	userRoleRank = map[string]int{
+		UserRoleUser:    1,
+		UserRoleSupport: 2,
+		UserRoleAdmin:   3,
+	}
+)
+
 type User struct {
-	ID        int64    `json:"id"`
-	FirstName string   `json:"first_name"`
-	LastName  string   `json:"last_name"`
-	Username  string   `json:"username"`
-	Email     string   `json:"email"`
-	Password  password `json:"-"`
-	IsActive  bool     `json:"is_active"`
-	CreatedAt string   `json:"created_at"`
-	UpdatedAt string   `json:"updated_at"`
+	ID            int64      `json:"id"`
+	FirstName     string     `json:"first_name"`
+	LastName      string     `json:"last_name"`
+	Username      string     `json:"username"`
+	Email         string     `json:"email"`
+	Password      password   `json:"-"`
+	IsActive      bool       `json:"is_active"`
+	Role          string     `json:"role"`
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+	CreatedAt     string     `json:"created_at"`
+	UpdatedAt     string     `json:"updated_at"`
+}
+
+// HasRole reports whether the user's role grants at least the permissions of required.
+func (u *User) HasRole(required string) bool {
+	return userRoleRank[u.Role] >= userRoleRank[required] && userRoleRank[required] > 0
+}
+
+// Outranks reports whether the user holds a strictly higher role than other.
+func (u *User) Outranks(other *User) bool {
+	return userRoleRank[u.Role] > userRoleRank[other.Role]
 }
 
 type password struct {
@@ -52,7 +84,7 @@ func (us *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 	query := `
 		INSERT INTO users (first_name, last_name, username, email, password)
 		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, is_active, created_at, updated_at
+		RETURNING id, is_active, role, created_at, updated_at
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
@@ -60,6 +92,7 @@ func (us *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 	err := tx.QueryRowContext(ctx, query, user.FirstName, user.LastName, user.Username, user.Email, user.Password.hash).Scan(
 		&user.ID,
 		&user.IsActive,
+		&user.Role,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -215,7 +248,7 @@ func (us *UserStore) Activate(ctx context.Context, token string) error {
 
 func (us *UserStore) GetUserByID(ctx context.Context, userID int64) (*User, error) {
 	query := `
-		SELECT id, first_name, last_name, username, email, password, is_active, created_at, updated_at
+		SELECT id, first_name, last_name, username, email, password, is_active, role, created_at, updated_at
 		FROM users
 		WHERE id = $1 AND is_active = true
 		`
@@ -233,6 +266,7 @@ func (us *UserStore) GetUserByID(ctx context.Context, userID int64) (*User, erro
 		&user.Email,
 		&user.Password.hash,
 		&user.IsActive,
+		&user.Role,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -265,7 +299,7 @@ func (us *UserStore) DeleteUser(ctx context.Context, userID int64) error {
 
 func (us *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
-		SELECT id, first_name, last_name, username, email, password, is_active, created_at, updated_at
+		SELECT id, first_name, last_name, username, email, password, is_active, role, created_at, updated_at
 		FROM users
 		WHERE email = $1 AND is_active = true
 		`
@@ -283,6 +317,7 @@ func (us *UserStore) GetByEmail(ctx context.Context, email string) (*User, error
 		&user.Email,
 		&user.Password.hash,
 		&user.IsActive,
+		&user.Role,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -314,3 +349,294 @@ func (us *UserStore) deleteUser(ctx context.Context, tx *sql.Tx, userID int64) e
 
 	return nil
 }
+
+// CreatePasswordReset stores a hashed reset token, replacing any earlier
+// token of the user so only the latest email works.
+func (us *UserStore) CreatePasswordReset(ctx context.Context, userID int64, token string, resetEXP time.Duration) error {
+	return withTX(us.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM password_resets WHERE user_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO password_resets (token, user_id, expiry)
+			VALUES ($1, $2, $3)
+		`
+		_, err = tx.ExecContext(ctx, query, token, userID, time.Now().Add(resetEXP))
+		return err
+	})
+}
+
+// ResetPassword sets the password held by user for the owner of an unexpired
+// reset token, consumes the token and ends every session of the owner. It
+// returns the IDs of the revoked sessions.
+func (us *UserStore) ResetPassword(ctx context.Context, token string, user *User) ([]string, error) {
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	var sessionIDs []string
+
+	err := withTX(us.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+		defer cancel()
+
+		query := `
+			DELETE FROM password_resets
+			WHERE token = $1 AND expiry > $2
+			RETURNING user_id
+		`
+		err := tx.QueryRowContext(ctx, query, hashToken, time.Now()).Scan(&user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE users SET password = $1, updated_at = NOW() WHERE id = $2`, user.Password.hash, user.ID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM password_resets WHERE user_id = $1`, user.ID)
+		if err != nil {
+			return err
+		}
+
+		sessionIDs, err = revokeUserSessions(ctx, tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sessionIDs, nil
+}
+
+// List returns the users matching the filter, including pending and
+// deactivated accounts.
+func (us *UserStore) List(ctx context.Context, fq *UserFilterQuery) ([]*User, error) {
+	query := `
+		SELECT id, first_name, last_name, username, email, is_active, role, deactivated_at, created_at, updated_at
+		FROM users
+		WHERE ($1 = '' OR username ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%')
+		AND ($2 = '' OR role = $2)
+		AND (
+			$3 = ''
+			OR ($3 = 'active' AND is_active = true)
+			OR ($3 = 'pending' AND is_active = false AND deactivated_at IS NULL)
+			OR ($3 = 'deactivated' AND deactivated_at IS NOT NULL)
+		)
+		ORDER BY id ASC
+		LIMIT $4 OFFSET $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	rows, err := us.db.QueryContext(ctx, query, fq.Search, fq.Role, fq.Status, fq.Limit, fq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		user := &User{}
+		err := rows.Scan(
+			&user.ID,
+			&user.FirstName,
+			&user.LastName,
+			&user.Username,
+			&user.Email,
+			&user.IsActive,
+			&user.Role,
+			&user.DeactivatedAt,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// GetAccount returns a user whatever the state of their account.
+func (us *UserStore) GetAccount(ctx context.Context, userID int64) (*User, error) {
+	query := `
+		SELECT id, first_name, last_name, username, email, is_active, role, deactivated_at, created_at, updated_at
+		FROM users
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	user := &User{}
+
+	err := us.db.QueryRowContext(ctx, query, userID).Scan(
+		&user.ID,
+		&user.FirstName,
+		&user.LastName,
+		&user.Username,
+		&user.Email,
+		&user.IsActive,
+		&user.Role,
+		&user.DeactivatedAt,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// Deactivate locks an active account out and ends every session of it. It
+// returns the IDs of the revoked sessions.
+func (us *UserStore) Deactivate(ctx context.Context, userID int64) ([]string, error) {
+	var sessionIDs []string
+
+	err := withTX(us.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+		defer cancel()
+
+		query := `
+			UPDATE users
+			SET is_active = false, deactivated_at = NOW(), updated_at = NOW()
+			WHERE id = $1 AND is_active = true
+		`
+		res, err := tx.ExecContext(ctx, query, userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM password_resets WHERE user_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+
+		sessionIDs, err = revokeUserSessions(ctx, tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sessionIDs, nil
+}
+
+// Reactivate restores a deactivated account.
+func (us *UserStore) Reactivate(ctx context.Context, userID int64) error {
+	query := `
+		UPDATE users
+		SET is_active = true, deactivated_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND deactivated_at IS NOT NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	res, err := us.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Reinvite replaces the invitations of an account that was never activated.
+func (us *UserStore) Reinvite(ctx context.Context, userID int64, token string, invitationEXP time.Duration) error {
+	return withTX(us.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+		defer cancel()
+
+		var pending bool
+		query := `
+			SELECT is_active = false AND deactivated_at IS NULL
+			FROM users
+			WHERE id = $1
+			FOR UPDATE
+		`
+		err := tx.QueryRowContext(ctx, query, userID).Scan(&pending)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+		if !pending {
+			return ErrNotPending
+		}
+
+		err = us.deleteUserInvitations(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+
+		return us.createUserInvitation(ctx, tx, token, invitationEXP, userID)
+	})
+}
+
+func (us *UserStore) SetRole(ctx context.Context, userID int64, role string) error {
+	query := `
+		UPDATE users
+		SET role = $1, updated_at = NOW()
+		WHERE id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	res, err := us.db.ExecContext(ctx, query, role, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
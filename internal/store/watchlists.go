@@ -0,0 +1,345 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateWatchlist = errors.New("watchlist name already exists")
+	ErrDuplicateSymbol    = errors.New("symbol already in watchlist")
+	ErrInvalidOrder       = errors.New("order must list every symbol in the watchlist exactly once")
+)
+
+type Watchlist struct {
+	ID        int64           `json:"id"`
+	UserID    int64           `json:"user_id"`
+	Name      string          `json:"name"`
+	ItemCount int             `json:"item_count"`
+	Items     []WatchlistItem `json:"items,omitempty"`
+	CreatedAt string          `json:"created_at"`
+	UpdatedAt string          `json:"updated_at"`
+}
+
+type WatchlistItem struct {
+	ID          int64  `json:"id"`
+	WatchlistID int64  `json:"watchlist_id"`
+	Symbol      string `json:"symbol"`
+	Note        string `json:"note"`
+	Position    int    `json:"position"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type WatchlistStore struct {
+	db *sql.DB
+}
+
+func (ws *WatchlistStore) Create(ctx context.Context, watchlist *Watchlist) error {
+	query := `
+		INSERT INTO watchlists (user_id, name)
+		VALUES ($1, $2)
+		RETURNING id, created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	err := ws.db.QueryRowContext(ctx, query, watchlist.UserID, watchlist.Name).Scan(
+		&watchlist.ID,
+		&watchlist.CreatedAt,
+		&watchlist.UpdatedAt,
+	)
+	if err != nil {
+		return watchlistError(err)
+	}
+
+	return nil
+}
+
+func (ws *WatchlistStore) GetByUser(ctx context.Context, userID int64) ([]Watchlist, error) {
+	query := `
+		SELECT w.id, w.user_id, w.name, COUNT(wi.id), w.created_at, w.updated_at
+		FROM watchlists w
+		LEFT JOIN watchlist_items wi ON wi.watchlist_id = w.id
+		WHERE w.user_id = $1
+		GROUP BY w.id
+		ORDER BY w.name ASC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	rows, err := ws.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	watchlists := []Watchlist{}
+	for rows.Next() {
+		var w Watchlist
+
+		err := rows.Scan(
+			&w.ID,
+			&w.UserID,
+			&w.Name,
+			&w.ItemCount,
+			&w.CreatedAt,
+			&w.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		watchlists = append(watchlists, w)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return watchlists, nil
+}
+
+// GetByID returns a user's watchlist with its items in display order.
+func (ws *WatchlistStore) GetByID(ctx context.Context, watchlistID int64, userID int64) (*Watchlist, error) {
+	query := `
+		SELECT id, user_id, name, created_at, updated_at
+		FROM watchlists
+		WHERE id = $1 AND user_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	var w Watchlist
+	err := ws.db.QueryRowContext(ctx, query, watchlistID, userID).Scan(
+		&w.ID,
+		&w.UserID,
+		&w.Name,
+		&w.CreatedAt,
+		&w.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	itemsQuery := `
+		SELECT id, watchlist_id, symbol, note, position, created_at
+		FROM watchlist_items
+		WHERE watchlist_id = $1
+		ORDER BY position ASC, id ASC
+	`
+
+	rows, err := ws.db.QueryContext(ctx, itemsQuery, watchlistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	w.Items = []WatchlistItem{}
+	for rows.Next() {
+		var item WatchlistItem
+
+		err := rows.Scan(
+			&item.ID,
+			&item.WatchlistID,
+			&item.Symbol,
+			&item.Note,
+			&item.Position,
+			&item.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		w.Items = append(w.Items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	w.ItemCount = len(w.Items)
+
+	return &w, nil
+}
+
+func (ws *WatchlistStore) Update(ctx context.Context, watchlist *Watchlist) error {
+	query := `
+		UPDATE watchlists
+		SET name = $1, updated_at = NOW()
+		WHERE id = $2 AND user_id = $3
+		RETURNING updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	err := ws.db.QueryRowContext(ctx, query, watchlist.Name, watchlist.ID, watchlist.UserID).Scan(&watchlist.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return watchlistError(err)
+		}
+	}
+
+	return nil
+}
+
+func (ws *WatchlistStore) Delete(ctx context.Context, watchlistID int64, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	res, err := ws.db.ExecContext(ctx, `DELETE FROM watchlists WHERE id = $1 AND user_id = $2`, watchlistID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// AddItem appends a symbol to the end of the watchlist.
+func (ws *WatchlistStore) AddItem(ctx context.Context, item *WatchlistItem) error {
+	query := `
+		INSERT INTO watchlist_items (watchlist_id, symbol, note, position)
+		VALUES ($1, $2, $3, (SELECT COALESCE(MAX(position), 0) + 1 FROM watchlist_items WHERE watchlist_id = $1))
+		RETURNING id, position, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	err := ws.db.QueryRowContext(ctx, query, item.WatchlistID, item.Symbol, item.Note).Scan(
+		&item.ID,
+		&item.Position,
+		&item.CreatedAt,
+	)
+	if err != nil {
+		return watchlistError(err)
+	}
+
+	return ws.touch(ctx, item.WatchlistID)
+}
+
+func (ws *WatchlistStore) UpdateItem(ctx context.Context, item *WatchlistItem) error {
+	query := `
+		UPDATE watchlist_items
+		SET note = $1
+		WHERE watchlist_id = $2 AND symbol = $3
+		RETURNING id, position, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	err := ws.db.QueryRowContext(ctx, query, item.Note, item.WatchlistID, item.Symbol).Scan(
+		&item.ID,
+		&item.Position,
+		&item.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return ws.touch(ctx, item.WatchlistID)
+}
+
+func (ws *WatchlistStore) RemoveItem(ctx context.Context, watchlistID int64, symbol string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+	defer cancel()
+
+	res, err := ws.db.ExecContext(ctx, `DELETE FROM watchlist_items WHERE watchlist_id = $1 AND symbol = $2`, watchlistID, symbol)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return ws.touch(ctx, watchlistID)
+}
+
+// Reorder sets the display order to the given symbols, which must be exactly
+// the symbols already in the watchlist.
+func (ws *WatchlistStore) Reorder(ctx context.Context, watchlistID int64, symbols []string) error {
+	return withTX(ws.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOut)
+		defer cancel()
+
+		var count int
+		err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM watchlist_items WHERE watchlist_id = $1`, watchlistID).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count != len(symbols) {
+			return ErrInvalidOrder
+		}
+
+		query := `
+			UPDATE watchlist_items wi
+			SET position = o.position
+			FROM unnest($2::text[]) WITH ORDINALITY AS o(symbol, position)
+			WHERE wi.watchlist_id = $1 AND wi.symbol = o.symbol
+		`
+
+		res, err := tx.ExecContext(ctx, query, watchlistID, pq.Array(symbols))
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if int(rows) != count {
+			return ErrInvalidOrder
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE watchlists SET updated_at = NOW() WHERE id = $1`, watchlistID)
+		return err
+	})
+}
+
+func (ws *WatchlistStore) touch(ctx context.Context, watchlistID int64) error {
+	_, err := ws.db.ExecContext(ctx, `UPDATE watchlists SET updated_at = NOW() WHERE id = $1`, watchlistID)
+	return err
+}
+
+func watchlistError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Constraint {
+		case "watchlists_user_id_name_key":
+			return ErrDuplicateWatchlist
+		case "watchlist_items_watchlist_id_symbol_key":
+			return ErrDuplicateSymbol
+		}
+	}
+	return err
+}